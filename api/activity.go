@@ -0,0 +1,51 @@
+package api
+
+import (
+	"time"
+
+	protos "github.com/pogodevorg/POGOProtos-go"
+)
+
+// Speeds in meters per second from which a player is considered to be moving in a certain way
+const walkingSpeed = 0.5
+const runningSpeed = 2.5
+const automotiveSpeed = 7.0
+
+// activityTimeout is the time after the last move from which a player is considered stationary again
+const activityTimeout = 30 * time.Second
+
+// movement keeps track of the speed of the player between the last two moves
+type movement struct {
+	movedAt time.Time
+	speed   float64
+}
+
+func (m *movement) update(from, to *Location, now time.Time) {
+	if from != nil && !m.movedAt.IsZero() {
+		elapsed := now.Sub(m.movedAt).Seconds()
+		if elapsed > 0 {
+			m.speed = from.DistanceTo(to) / elapsed
+		}
+	}
+	m.movedAt = now
+}
+
+func (m *movement) activityStatus(now time.Time) *protos.Signature_ActivityStatus {
+	speed := m.speed
+	if m.movedAt.IsZero() || now.Sub(m.movedAt) > activityTimeout {
+		speed = 0
+	}
+
+	status := &protos.Signature_ActivityStatus{}
+	switch {
+	case speed < walkingSpeed:
+		status.Stationary = true
+	case speed < runningSpeed:
+		status.Walking = true
+	case speed < automotiveSpeed:
+		status.Running = true
+	default:
+		status.Automotive = true
+	}
+	return status
+}
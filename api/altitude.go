@@ -0,0 +1,42 @@
+package api
+
+import (
+	"github.com/golang/geo/s2"
+)
+
+// altitudeCellLevel is the level of the s2 cells the looked up altitudes are cached for, about 70 meters across
+const altitudeCellLevel = 17
+
+// AltitudeProvider looks up the altitude in meters at a coordinate, for example from an elevation model
+type AltitudeProvider interface {
+	Altitude(lat, lng float64) (float64, error)
+}
+
+// SetAltitudeProvider makes MoveTo fill in the altitude of the new location with the altitude from the provider,
+// the altitudes are cached per small area. Without a provider the altitude of the location is used as is
+func (s *Session) SetAltitudeProvider(provider AltitudeProvider) {
+	s.altitudeProvider = provider
+	s.altitudes = nil
+}
+
+// lookupAltitude sets the altitude of the location from the altitude provider, on a failed lookup
+// the altitude of the location is left untouched
+func (s *Session) lookupAltitude(location *Location) {
+	if s.altitudeProvider == nil {
+		return
+	}
+	cellID := uint64(s2.CellIDFromLatLng(s2.LatLngFromDegrees(location.Lat, location.Lon)).Parent(altitudeCellLevel))
+	if alt, ok := s.altitudes[cellID]; ok {
+		location.Alt = alt
+		return
+	}
+	alt, err := s.altitudeProvider.Altitude(location.Lat, location.Lon)
+	if err != nil {
+		return
+	}
+	if s.altitudes == nil {
+		s.altitudes = make(map[uint64]float64)
+	}
+	s.altitudes[cellID] = alt
+	location.Alt = alt
+}
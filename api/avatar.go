@@ -0,0 +1,51 @@
+package api
+
+import (
+	"errors"
+
+	"github.com/golang/protobuf/proto"
+	"golang.org/x/net/context"
+
+	protos "github.com/pogodevorg/POGOProtos-go"
+)
+
+// SetAvatar sets the avatar of the player, the remote service has no standalone call for the gender
+// so it is sent along with the skin and the clothing in the avatar, an unknown gender gives ErrInvalidGender
+func (s *Session) SetAvatar(ctx context.Context, avatar *protos.PlayerAvatar, proxyId int64) (*protos.SetAvatarResponse, error) {
+	if avatar == nil {
+		return nil, ErrFormatting
+	}
+	if _, ok := protos.Gender_name[int32(avatar.Gender)]; !ok {
+		return nil, ErrInvalidGender
+	}
+
+	requestMessage, err := proto.Marshal(&protos.SetAvatarMessage{
+		PlayerAvatar: avatar,
+	})
+	if err != nil {
+		return nil, ErrFormatting
+	}
+
+	requests := []*protos.Request{{RequestType: protos.RequestType_SET_AVATAR, RequestMessage: requestMessage}}
+	response, err := s.Call(ctx, requests, proxyId)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(response.Returns) < 1 {
+		return nil, errors.New("Empty response")
+	}
+
+	setAvatar := &protos.SetAvatarResponse{}
+	err = unmarshalChecked(response.Returns[0], setAvatar)
+	if err != nil {
+		return nil, err
+	}
+	if setAvatar.Status == protos.SetAvatarResponse_SUCCESS && setAvatar.PlayerData != nil && s.playerCache.player != nil {
+		s.playerCache.player.PlayerData = setAvatar.PlayerData
+	}
+	s.feed.Push(setAvatar)
+	s.debugProtoMessage("response return[0]", setAvatar)
+
+	return setAvatar, newErrCall(requests, response, GetErrorFromStatus(response.StatusCode))
+}
@@ -0,0 +1,92 @@
+package api
+
+import (
+	"time"
+
+	protos "github.com/pogodevorg/POGOProtos-go"
+)
+
+// defaultMaxBagSize is the item storage of an account without bag upgrades
+const defaultMaxBagSize = 350
+
+// maxInventoryAge is how long a fetched inventory is trusted for the bag check
+const maxInventoryAge = 5 * time.Minute
+
+// bagTracker keeps the number of items in the bag as of the last fetched inventory,
+// adjusted by the items awarded and recycled since
+type bagTracker struct {
+	check     bool
+	maxSize   int32
+	items     int32
+	counts    map[protos.ItemId]int32
+	fetchedAt time.Time
+}
+
+// SetBagFullCheck makes FortSearch refuse to spin with ErrBagFull when the bag is full.
+// The check uses the inventory last fetched with GetInventory, which must not be older than a few minutes,
+// together with the items awarded by FortSearch and recycled with RecycleInventoryItem since
+func (s *Session) SetBagFullCheck(check bool) {
+	s.bag.check = check
+}
+
+// SetMaxBagSize sets the item storage used by the bag check, by default the storage
+// of the cached player data is used or the storage of an account without upgrades
+func (s *Session) SetMaxBagSize(size int32) {
+	s.bag.maxSize = size
+}
+
+// trackBag counts the items in a fetched inventory
+func (s *Session) trackBag(inventory *protos.GetInventoryResponse) {
+	counts := ItemCounts(inventory)
+	var total int32
+	for _, count := range counts {
+		total += count
+	}
+	s.bag.items = total
+	s.bag.counts = counts
+	s.bag.fetchedAt = time.Now()
+}
+
+// trackBagAwards adds the items awarded by a fort search to the bag
+func (s *Session) trackBagAwards(awards []*protos.ItemAward) {
+	if s.bag.counts == nil {
+		return
+	}
+	for _, award := range awards {
+		s.bag.counts[award.ItemId] += award.ItemCount
+		s.bag.items += award.ItemCount
+	}
+}
+
+// trackBagRecycle sets the count of a recycled item to the count the remote service responded with
+func (s *Session) trackBagRecycle(itemID protos.ItemId, recycle *protos.RecycleInventoryItemResponse) {
+	if s.bag.counts == nil || recycle.Result != protos.RecycleInventoryItemResponse_SUCCESS {
+		return
+	}
+	s.bag.items += recycle.NewCount - s.bag.counts[itemID]
+	s.bag.counts[itemID] = recycle.NewCount
+}
+
+func (s *Session) getMaxBagSize() int32 {
+	if s.bag.maxSize > 0 {
+		return s.bag.maxSize
+	}
+	if s.playerCache.player != nil && s.playerCache.player.PlayerData != nil && s.playerCache.player.PlayerData.MaxItemStorage > 0 {
+		return s.playerCache.player.PlayerData.MaxItemStorage
+	}
+	return defaultMaxBagSize
+}
+
+// checkBag returns ErrBagFull when the bag check is enabled and the bag is full
+func (s *Session) checkBag() error {
+	if !s.bag.check {
+		return nil
+	}
+	if s.bag.fetchedAt.IsZero() || time.Since(s.bag.fetchedAt) > maxInventoryAge {
+		return ErrStaleInventory
+	}
+	if s.bag.items >= s.getMaxBagSize() {
+		return ErrBagFull
+	}
+	return nil
+}
@@ -0,0 +1,51 @@
+package api
+
+import (
+	"math"
+
+	protos "github.com/pogodevorg/POGOProtos-go"
+)
+
+// razzBerryMultiplier is the capture multiplier of a razz berry
+const razzBerryMultiplier = 1.5
+
+// CatchRecommendation is the throw that gives the best chance of catching an encountered pokémon
+type CatchRecommendation struct {
+	Ball        protos.ItemId
+	UseBerry    bool
+	Probability float64
+}
+
+// RecommendCatch picks the ball and whether to throw a razz berry first to maximize the capture probability,
+// based on the probabilities sent with the encounter and the items held. A master ball is only recommended
+// when no other ball is held. The boolean is false when no ball is held at all
+func RecommendCatch(encounter *protos.EncounterResponse, items map[protos.ItemId]int32) (CatchRecommendation, bool) {
+	var best CatchRecommendation
+	found := false
+	if encounter.CaptureProbability == nil {
+		return best, false
+	}
+
+	useBerry := items[protos.ItemId_ITEM_RAZZ_BERRY] > 0
+	probabilities := encounter.CaptureProbability.CaptureProbability
+	for idx, ball := range encounter.CaptureProbability.PokeballType {
+		if idx >= len(probabilities) || items[ball] <= 0 {
+			continue
+		}
+		probability := float64(probabilities[idx])
+		if useBerry {
+			probability = 1 - math.Pow(1-probability, razzBerryMultiplier)
+		}
+
+		candidate := CatchRecommendation{Ball: ball, UseBerry: useBerry, Probability: probability}
+		switch {
+		case !found:
+			best, found = candidate, true
+		case best.Ball == protos.ItemId_ITEM_MASTER_BALL:
+			best = candidate
+		case ball != protos.ItemId_ITEM_MASTER_BALL && probability > best.Probability:
+			best = candidate
+		}
+	}
+	return best, found
+}
@@ -0,0 +1,74 @@
+package api
+
+import (
+	"github.com/golang/protobuf/proto"
+	"golang.org/x/net/context"
+
+	protos "github.com/pogodevorg/POGOProtos-go"
+)
+
+// detectChallenge updates the challenge state of the session from the challenge check of a call, if there is one
+func (s *Session) detectChallenge(requests []*protos.Request, response *protos.ResponseEnvelope) {
+	challengeReturn, ok := getReturn(requests, response, protos.RequestType_CHECK_CHALLENGE)
+	if !ok {
+		return
+	}
+	challenge := &protos.CheckChallengeResponse{}
+	if proto.Unmarshal(challengeReturn, challenge) != nil {
+		return
+	}
+	s.challengeActive = challenge.ShowChallenge
+	s.challengeURL = challenge.ChallengeUrl
+}
+
+// HasChallenge checks whether the last challenge check of the session asked for a ReCaptcha to be solved
+func (s *Session) HasChallenge() bool {
+	s.callMu.Lock()
+	defer s.callMu.Unlock()
+	return s.challengeActive
+}
+
+// ChallengeURL returns the url of the ReCaptcha challenge, or an empty string when there is no challenge
+func (s *Session) ChallengeURL() string {
+	s.callMu.Lock()
+	defer s.callMu.Unlock()
+	if !s.challengeActive {
+		return ""
+	}
+	return s.challengeURL
+}
+
+func (s *Session) clearChallenge() {
+	s.callMu.Lock()
+	defer s.callMu.Unlock()
+	s.challengeActive = false
+	s.challengeURL = ""
+}
+
+// CaptchaSolver solves the ReCaptcha challenge at challengeURL and returns the solution token
+type CaptchaSolver func(ctx context.Context, challengeURL string) (token string, err error)
+
+// SetCaptchaSolver makes Call solve a detected challenge with solver, submit the solution through the proxy
+// of the call and then retry the original requests. When no solver is set challenges have to be handled manually
+func (s *Session) SetCaptchaSolver(solver CaptchaSolver) {
+	s.captchaSolver = solver
+}
+
+// solveChallenge solves the current challenge with the captcha solver of the session,
+// the solution is sent through the proxy of the call the challenge was detected in.
+// Only one challenge is solved at a time, calls that detected the same challenge wait for it
+func (s *Session) solveChallenge(ctx context.Context, proxyId int64) error {
+	s.recoveryMu.Lock()
+	defer s.recoveryMu.Unlock()
+
+	challengeURL := s.ChallengeURL()
+	if challengeURL == "" {
+		return nil
+	}
+	token, err := s.captchaSolver(ctx, challengeURL)
+	if err != nil {
+		return err
+	}
+	_, err = s.SolveCaptchaWithProxy(withRecovery(ctx), token, proxyId)
+	return err
+}
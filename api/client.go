@@ -0,0 +1,50 @@
+package api
+
+import (
+	"time"
+
+	"golang.org/x/net/context"
+
+	protos "github.com/pogodevorg/POGOProtos-go"
+)
+
+// GameClient is implemented by Session, packages building on this one can depend on it
+// instead of on *Session so the client can be replaced in their tests
+type GameClient interface {
+	Call(ctx context.Context, requests []*protos.Request, proxyId int64) (*protos.ResponseEnvelope, error)
+	Init(ctx context.Context, proxyId int64) error
+	WarmUp(ctx context.Context, proxyId int64) error
+	MoveTo(location *Location)
+
+	Announce(ctx context.Context, proxyId int64, options ...MapOption) (*protos.GetMapObjectsResponse, error)
+	AnnounceWith(ctx context.Context, extra []*protos.Request, proxyId int64) (*protos.GetMapObjectsResponse, map[protos.RequestType][]byte, error)
+	GetPlayerMap(ctx context.Context, proxyId int64, options ...MapOption) (*protos.GetMapObjectsResponse, error)
+	GetMapObjects(ctx context.Context, cellIDs []uint64, proxyId int64, options ...MapOption) (*protos.GetMapObjectsResponse, error)
+	GetMapObjectsBatched(ctx context.Context, cellIDs []uint64, batchSize int, proxyId int64, options ...MapOption) (*protos.GetMapObjectsResponse, error)
+	ScanBounds(ctx context.Context, minLat, minLng, maxLat, maxLng float64, proxyId int64, options ...MapOption) (*protos.GetMapObjectsResponse, error)
+	GetSpawnPoints(ctx context.Context, proxyId int64) ([]MapSpawnPoint, error)
+
+	CheckChallenge(ctx context.Context) (*protos.CheckChallengeResponse, error)
+	SolveCaptcha(ctx context.Context, solution string) (*protos.VerifyChallengeResponse, error)
+	CheckChallengeWithProxy(ctx context.Context, proxyId int64) (*protos.CheckChallengeResponse, error)
+	SolveCaptchaWithProxy(ctx context.Context, solution string, proxyId int64) (*protos.VerifyChallengeResponse, error)
+
+	GetPlayer(ctx context.Context, proxyId int64) (*protos.GetPlayerResponse, error)
+	RefreshPlayer(ctx context.Context, proxyId int64) (*protos.GetPlayerResponse, error)
+	SetPlayerTeam(ctx context.Context, team protos.TeamColor, proxyId int64) (*protos.SetPlayerTeamResponse, error)
+	SetAvatar(ctx context.Context, avatar *protos.PlayerAvatar, proxyId int64) (*protos.SetAvatarResponse, error)
+	GetInventory(ctx context.Context, proxyId int64) (*protos.GetInventoryResponse, error)
+	GetStoreItems(ctx context.Context, proxyId int64) ([]*protos.GetStoreItemsResponse_StoreItem, error)
+	DownloadRemoteConfigVersion(ctx context.Context, proxyId int64) (*protos.DownloadRemoteConfigVersionResponse, error)
+
+	Encounter(ctx context.Context, encounterID uint64, spawnID string, loc *Location, proxyId int64) (*protos.EncounterResponse, error)
+	UseItemCapture(ctx context.Context, encounterID uint64, spawnID string, itemID protos.ItemId, proxyId int64) (*protos.UseItemCaptureResponse, error)
+	ReleasePokemon(ctx context.Context, pokemonID uint64, family protos.PokemonFamilyId, proxyId int64) (*ReleaseResult, error)
+	FortSearch(ctx context.Context, fort *protos.FortData, proxyId int64) (*protos.FortSearchResponse, error)
+	RecycleInventoryItem(ctx context.Context, itemID protos.ItemId, count int32, proxyId int64) (*protos.RecycleInventoryItemResponse, error)
+	RecycleItemsToTarget(ctx context.Context, targets map[protos.ItemId]int32, inventory *protos.GetInventoryResponse, proxyId int64) (map[protos.ItemId]*protos.RecycleInventoryItemResponse, error)
+
+	Ping(ctx context.Context, proxyId int64) (time.Duration, error)
+}
+
+var _ GameClient = (*Session)(nil)
@@ -0,0 +1,63 @@
+package api
+
+import (
+	"testing"
+
+	"golang.org/x/net/context"
+
+	protos "github.com/pogodevorg/POGOProtos-go"
+)
+
+// fuzzResponse answers every request of the envelope with the same return
+func fuzzResponse(data []byte) func(request *protos.RequestEnvelope) *protos.ResponseEnvelope {
+	return func(request *protos.RequestEnvelope) *protos.ResponseEnvelope {
+		returns := make([][]byte, len(request.Requests))
+		for i := range returns {
+			returns[i] = data
+		}
+		return &protos.ResponseEnvelope{
+			StatusCode: protos.ResponseEnvelope_OK,
+			RequestId:  request.RequestId,
+			Returns:    returns,
+		}
+	}
+}
+
+func FuzzAnnounceDecode(f *testing.F) {
+	f.Add([]byte{})
+	f.Add([]byte{0x08, 0x01})
+	f.Add([]byte{0x0a, 0x05, 0x08, 0x01, 0x10, 0x02})
+	f.Add([]byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x01})
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		s, _, _ := newTestSession(fuzzResponse(data))
+		s.setURL("pgorelease.example/plfe/1")
+		s.setTicket(testTicket())
+
+		mapObjects, err := s.Announce(context.Background(), -1)
+		if err == nil && mapObjects == nil {
+			t.Errorf("Announce gave no map objects and no error for the return %x", data)
+		}
+	})
+}
+
+func FuzzEncounterDecode(f *testing.F) {
+	f.Add([]byte{})
+	f.Add([]byte{0x08, 0x01})
+	f.Add([]byte{0x12, 0x03, 0x08, 0x10, 0x18})
+	f.Add([]byte{0x12, 0xff, 0xff, 0xff, 0xff, 0x0f})
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		s, _, _ := newTestSession(fuzzResponse(data))
+		s.setURL("pgorelease.example/plfe/1")
+		s.setTicket(testTicket())
+
+		encounter, err := s.Encounter(context.Background(), 1, "47c3a7e8b1d", nil, -1)
+		if err == nil && encounter == nil {
+			t.Errorf("Encounter gave no response and no error for the return %x", data)
+		}
+		if err != nil && encounter != nil {
+			t.Errorf("Encounter gave a response along with the error %v for the return %x", err, data)
+		}
+	})
+}
@@ -0,0 +1,11 @@
+// Package api is a client for the Pokémon Go API built on the linked POGOProtos-go.
+//
+// Some parts of the game are newer than the linked protos, which have no request types or messages for them.
+// They can't be supported without upgrading the protos, and the request signing that changed along with them:
+//
+//   - The friend system: the friend list, sending friend invites and accepting them
+//   - Gifts: sending gifts to friends and opening them
+//   - Weather: the weather of the map cells, the weather alerts and the GET_WEATHER request
+//   - Notifications: the inbox and acknowledging its notifications
+//   - Raids: the raid info and badge data of gyms, the forts of the linked protos carry neither
+package api
@@ -0,0 +1,55 @@
+package api
+
+import (
+	"encoding/binary"
+	"io"
+	"sync"
+)
+
+// Record kinds of an envelope dump
+const (
+	EnvelopeDumpRequest  byte = 1
+	EnvelopeDumpResponse byte = 2
+)
+
+// envelopeDump writes raw envelopes as records of a kind byte, a big endian uint32 length and the envelope bytes
+type envelopeDump struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func (d *envelopeDump) write(kind byte, envelope []byte) {
+	if d == nil {
+		return
+	}
+	header := make([]byte, 5)
+	header[0] = kind
+	binary.BigEndian.PutUint32(header[1:], uint32(len(envelope)))
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.w.Write(header)
+	d.w.Write(envelope)
+}
+
+// SetEnvelopeDump makes the RPC client write the raw bytes of every request and response envelope to w,
+// a nil writer disables the dump
+func (c *RPC) SetEnvelopeDump(w io.Writer) {
+	var dump *envelopeDump
+	if w != nil {
+		dump = &envelopeDump{w: w}
+	}
+	c.dump.Store(dump)
+}
+
+// envelopeDump returns the dump set with SetEnvelopeDump, or nil when there is none
+func (c *RPC) envelopeDump() *envelopeDump {
+	dump, _ := c.dump.Load().(*envelopeDump)
+	return dump
+}
+
+// SetEnvelopeDump writes the raw bytes of every request and response envelope of the session to w,
+// regardless of the debug setting
+func (s *Session) SetEnvelopeDump(w io.Writer) {
+	s.rpc.SetEnvelopeDump(w)
+}
@@ -3,6 +3,10 @@ package api
 import (
 	"errors"
 	"fmt"
+	"strings"
+
+	"github.com/golang/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
 
 	protos "github.com/pogodevorg/POGOProtos-go"
 )
@@ -12,6 +16,9 @@ var ErrCheckChallenge = errors.New("ReCaptcha challenge was sent")
 
 type ErrCheckChallengeURL error
 
+// ErrCaptchaFailed happens when the remote service did not accept a ReCaptcha solution
+var ErrCaptchaFailed = errors.New("ReCaptcha solution was not accepted")
+
 // ErrFormatting happens when the something in the request body was not right
 var ErrFormatting = errors.New("Request was malformatted and could not be performed")
 
@@ -27,6 +34,13 @@ var ErrInvalidRequest = errors.New("The remote service responded but appear to t
 // ErrInvalidPlatformRequest happens when a platform specific request like the request signature being incorrect
 var ErrInvalidPlatformRequest = errors.New("A platform specific request is invalid")
 
+// ErrThrottled happens when a call is answered with status code 52, which the remote service also uses when it
+// throttles the session, the next calls are held back increasingly long. It matches ErrInvalidPlatformRequest with errors.Is
+var ErrThrottled error = &wrappedError{
+	message: fmt.Sprintf("The session is throttled (status code %d)", protos.ResponseEnvelope_INVALID_PLATFORM_REQUEST),
+	err:     ErrInvalidPlatformRequest,
+}
+
 // ErrSessionInvalidated happens when the session has been invalidated by the remote service
 var ErrSessionInvalidated = errors.New("The session has been invalidated")
 
@@ -36,22 +50,93 @@ var ErrInvalidAuthToken = errors.New("The auth token used is not vailid")
 // ErrRedirect happens when an invalid session endpoint has been used
 var ErrRedirect = errors.New("The request was redirected")
 
+// ErrTooManyRedirects happens when the remote service keeps redirecting the request to a new endpoint
+var ErrTooManyRedirects = errors.New("The request was redirected too many times")
+
 // ErrRequest happens when there is an unknown issue with the request
 var ErrRequest = errors.New("The remote service responded but the request could not be completed for unknown reasons")
 
 // ErrNoURL happens when the remote service is expected to respond with a remote URL but doesn't
 var ErrNoURL = errors.New("The remote service did not respond with a remote URL when expected")
 
+// ErrNoReturn happens when the response does not contain a return for a request
+var ErrNoReturn = errors.New("The response does not contain a return for the request")
+
+// ErrNoAuthTicket happens when the remote service is expected to respond with an auth ticket but doesn't
+var ErrNoAuthTicket = errors.New("The remote service did not respond with an auth ticket when expected")
+
+// ErrTicketExpired happens when an auth ticket is used that has already expired
+var ErrTicketExpired = errors.New("The auth ticket has expired")
+
+// ErrFortCooldown happens when a fort is searched again before its cooldown has passed
+var ErrFortCooldown = errors.New("The fort is still in its cooldown period")
+
+// ErrFortOutOfRange happens when a fort is used from further away than the interaction range of the game settings
+var ErrFortOutOfRange = errors.New("The fort is out of range")
+
+// ErrBagFull happens when a fort is searched while the bag can't hold any more items
+var ErrBagFull = errors.New("The bag is full")
+
+// ErrStaleInventory happens when the bag is checked without a recently fetched inventory
+var ErrStaleInventory = errors.New("The inventory has not been fetched recently")
+
+// ErrNoSigner happens when a request has to be signed but the session has neither a signer nor a hasher
+var ErrNoSigner = errors.New("The session has no signer to sign the request with")
+
+// ErrUnsupportedAPIVersion happens when a call has to be signed locally for another API version than DefaultAPIVersion,
+// other versions need a hasher for them like a HashServer
+var ErrUnsupportedAPIVersion = errors.New("The local signer does not support the API version")
+
+// ErrPositionMismatch happens when the player position of a request differs from the location of the session
+var ErrPositionMismatch = errors.New("The player position does not match the location of the session")
+
+// ErrTooManyCells happens when more map cells are requested at once than the remote service accepts
+var ErrTooManyCells = errors.New("Too many map cells requested at once")
+
+// ErrNotMoved happens when the map is requested again before the player moved far enough from the last map request
+var ErrNotMoved = errors.New("The player has not moved far enough since the last map request")
+
 // ErrProxyDead happens when the provided proxy does not respond.
 var ErrProxyDead = errors.New("Dead proxy")
 
-// ErrAccountBanned happens when a request is sent with a banned account
-var ErrAccountBanned = errors.New("Account is banned")
+// ErrUnknownProxy happens when a request is sent through a proxy id that isn't registered and there is no proxy host
+var ErrUnknownProxy = errors.New("Unknown proxy")
+
+// ErrAccountBanned happens when a request is sent with a banned account, the remote service signals this with status code 3.
+// As the status code is the one of a bad request it also matches ErrBadRequest with errors.Is
+var ErrAccountBanned error = &wrappedError{
+	message: fmt.Sprintf("Account is banned (status code %d)", protos.ResponseEnvelope_BAD_REQUEST),
+	err:     ErrBadRequest,
+}
 
 // ErrIpSoftBanned happens when a request is sent from a soft banned ip
 var ErrIpSoftBanned = errors.New("IP is softbanned")
 
+// ErrSoftBanned happens when the map keeps showing forts but no pokémon, which is how a soft banned account sees the map
+var ErrSoftBanned = errors.New("Account appears to be softbanned")
+
+// ErrTeamAlreadySet happens when a team is picked for a player that already has a team
+var ErrTeamAlreadySet = errors.New("The player has already picked a team")
+
+// ErrInvalidGender happens when an avatar is set with a gender the protos don't know
+var ErrInvalidGender = errors.New("The avatar has an invalid gender")
+
+// ErrProtoMismatch happens when a return decodes into an empty message, none of its fields are known to the
+// linked protos, which means the protos of the remote service no longer match the linked protos
+var ErrProtoMismatch = errors.New("The response does not match the known protos")
+
 // GetErrorFromStatus will, depending on the status code, give you an error or nil if there is no error
+//
+//	1   OK                        nil
+//	2   OK_RPC_URL_IN_RESPONSE    ErrNewRPCURL
+//	3   BAD_REQUEST               ErrAccountBanned, which wraps ErrBadRequest
+//	51  INVALID_REQUEST           ErrInvalidRequest
+//	52  INVALID_PLATFORM_REQUEST  ErrInvalidPlatformRequest
+//	53  REDIRECT                  ErrRedirect
+//	100 SESSION_INVALIDATED       ErrSessionInvalidated
+//	102 INVALID_AUTH_TOKEN        ErrInvalidAuthToken
+//
+// Any other status code gives ErrRequest
 func GetErrorFromStatus(status protos.ResponseEnvelope_StatusCode) error {
 	switch status {
 	case protos.ResponseEnvelope_OK:
@@ -59,7 +144,7 @@ func GetErrorFromStatus(status protos.ResponseEnvelope_StatusCode) error {
 	case protos.ResponseEnvelope_OK_RPC_URL_IN_RESPONSE:
 		return ErrNewRPCURL
 	case protos.ResponseEnvelope_BAD_REQUEST:
-		return ErrBadRequest
+		return ErrAccountBanned
 	case protos.ResponseEnvelope_INVALID_REQUEST:
 		return ErrInvalidRequest
 	case protos.ResponseEnvelope_INVALID_PLATFORM_REQUEST:
@@ -75,6 +160,45 @@ func GetErrorFromStatus(status protos.ResponseEnvelope_StatusCode) error {
 	}
 }
 
+// unmarshalChecked decodes a return and guards against version skew between the protos. A return the linked protos
+// know none of the fields of decodes into an empty message, which gives ErrProtoMismatch. Fields they don't know
+// next to ones they do are kept as unknown fields, as the remote service adds fields over time
+func unmarshalChecked(buf []byte, pb proto.Message) error {
+	err := proto.Unmarshal(buf, pb)
+	if err != nil {
+		return &ErrResponse{err}
+	}
+	if len(buf) > 0 && !hasKnownFields(proto.MessageReflect(pb)) {
+		return ErrProtoMismatch
+	}
+	return nil
+}
+
+// hasKnownFields checks whether any field of the linked protos is set in the message
+func hasKnownFields(m protoreflect.Message) bool {
+	known := false
+	m.Range(func(fd protoreflect.FieldDescriptor, v protoreflect.Value) bool {
+		known = true
+		return false
+	})
+	return known
+}
+
+// wrappedError is a more specific error for a status code that still matches the generic error of the status code
+type wrappedError struct {
+	message string
+	err     error
+}
+
+func (e *wrappedError) Error() string {
+	return e.message
+}
+
+// Unwrap gives the generic error of the status code
+func (e *wrappedError) Unwrap() error {
+	return e.err
+}
+
 // ErrResponse happens when there's something wrong with the response object
 type ErrResponse struct {
 	err error
@@ -83,3 +207,63 @@ type ErrResponse struct {
 func (e *ErrResponse) Error() string {
 	return fmt.Sprintf("The response could not be read: %s", e.err.Error())
 }
+
+// ErrCall happens when a call to the remote service fails, it records which requests were sent
+// and the status code the remote service responded with, the underlying error is kept in Err
+type ErrCall struct {
+	RequestTypes         []protos.RequestType
+	PlatformRequestTypes []protos.PlatformRequestType
+	StatusCode           protos.ResponseEnvelope_StatusCode
+	Err                  error
+}
+
+func (e *ErrCall) Error() string {
+	types := make([]string, 0, len(e.RequestTypes)+len(e.PlatformRequestTypes))
+	for _, requestType := range e.RequestTypes {
+		types = append(types, requestType.String())
+	}
+	for _, requestType := range e.PlatformRequestTypes {
+		types = append(types, requestType.String())
+	}
+	if len(types) == 0 {
+		types = append(types, "Request")
+	}
+	if e.StatusCode == 0 {
+		return fmt.Sprintf("%s failed: %s", strings.Join(types, ", "), e.Err.Error())
+	}
+	return fmt.Sprintf("%s failed with status %d: %s", strings.Join(types, ", "), e.StatusCode, e.Err.Error())
+}
+
+// Unwrap gives the underlying error, so errors.Is keeps matching errors like ErrProxyDead
+func (e *ErrCall) Unwrap() error {
+	return e.Err
+}
+
+// newErrCall wraps err with the request types and the status code of the response, nil stays nil
+func newErrCall(requests []*protos.Request, response *protos.ResponseEnvelope, err error) error {
+	if err == nil {
+		return nil
+	}
+	if _, ok := err.(*ErrCall); ok {
+		return err
+	}
+	callErr := &ErrCall{Err: err}
+	for _, request := range requests {
+		callErr.RequestTypes = append(callErr.RequestTypes, request.RequestType)
+	}
+	if response != nil {
+		callErr.StatusCode = response.StatusCode
+	}
+	return callErr
+}
+
+// newPlatformErrCall works like newErrCall for a call made with platform requests only
+func newPlatformErrCall(platformRequests []*protos.RequestEnvelope_PlatformRequest, response *protos.ResponseEnvelope, err error) error {
+	err = newErrCall(nil, response, err)
+	if callErr, ok := err.(*ErrCall); ok && len(callErr.PlatformRequestTypes) == 0 {
+		for _, request := range platformRequests {
+			callErr.PlatformRequestTypes = append(callErr.PlatformRequestTypes, request.Type)
+		}
+	}
+	return err
+}
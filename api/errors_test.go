@@ -0,0 +1,67 @@
+package api
+
+import (
+	"errors"
+	"testing"
+
+	protos "github.com/pogodevorg/POGOProtos-go"
+)
+
+func TestGetErrorFromStatus(t *testing.T) {
+	tests := []struct {
+		status protos.ResponseEnvelope_StatusCode
+		err    error
+	}{
+		{protos.ResponseEnvelope_OK, nil},
+		{protos.ResponseEnvelope_OK_RPC_URL_IN_RESPONSE, ErrNewRPCURL},
+		{protos.ResponseEnvelope_BAD_REQUEST, ErrAccountBanned},
+		{protos.ResponseEnvelope_INVALID_REQUEST, ErrInvalidRequest},
+		{protos.ResponseEnvelope_INVALID_PLATFORM_REQUEST, ErrInvalidPlatformRequest},
+		{protos.ResponseEnvelope_REDIRECT, ErrRedirect},
+		{protos.ResponseEnvelope_SESSION_INVALIDATED, ErrSessionInvalidated},
+		{protos.ResponseEnvelope_INVALID_AUTH_TOKEN, ErrInvalidAuthToken},
+		{protos.ResponseEnvelope_StatusCode(99), ErrRequest},
+	}
+
+	for _, test := range tests {
+		err := GetErrorFromStatus(test.status)
+		if err != test.err {
+			t.Errorf("GetErrorFromStatus(%d) = %v, expected %v", test.status, err, test.err)
+		}
+	}
+}
+
+func TestAccountBannedIsBadRequest(t *testing.T) {
+	if !errors.Is(GetErrorFromStatus(protos.ResponseEnvelope_BAD_REQUEST), ErrBadRequest) {
+		t.Error("The error of status code 3 does not match ErrBadRequest")
+	}
+}
+
+func TestThrottledIsInvalidPlatformRequest(t *testing.T) {
+	if !errors.Is(ErrThrottled, ErrInvalidPlatformRequest) {
+		t.Error("ErrThrottled does not match ErrInvalidPlatformRequest")
+	}
+}
+
+func TestUnmarshalChecked(t *testing.T) {
+	tests := []struct {
+		buf []byte
+		err error
+	}{
+		// An empty return is an empty message
+		{[]byte{}, nil},
+		// success = true
+		{[]byte{0x08, 0x01}, nil},
+		// success = true and field 999, which the linked protos don't know
+		{[]byte{0x08, 0x01, 0xb8, 0x3e, 0x01}, nil},
+		// Only field 999
+		{[]byte{0xb8, 0x3e, 0x01}, ErrProtoMismatch},
+	}
+
+	for _, test := range tests {
+		err := unmarshalChecked(test.buf, &protos.GetPlayerResponse{})
+		if err != test.err {
+			t.Errorf("unmarshalChecked(%x) = %v, expected %v", test.buf, err, test.err)
+		}
+	}
+}
@@ -1,5 +1,16 @@
 package api
 
+import (
+	"encoding/json"
+	"io"
+	"reflect"
+	"sync"
+	"sync/atomic"
+
+	"github.com/golang/protobuf/jsonpb"
+	"github.com/golang/protobuf/proto"
+)
+
 // Feed is a common interface to act on encountered
 type Feed interface {
 	// Push is used to put response messages on to the feed
@@ -14,3 +25,139 @@ type VoidFeed struct {
 func (f *VoidFeed) Push(entry interface{}) {
 	// NOOP
 }
+
+// JSONFeed is a feed that writes every pushed message as a line of JSON
+type JSONFeed struct {
+	mu        sync.Mutex
+	w         io.Writer
+	marshaler *jsonpb.Marshaler
+	err       error
+}
+
+type jsonFeedEntry struct {
+	Type    string          `json:"type"`
+	Message json.RawMessage `json:"message"`
+}
+
+// NewJSONFeed constructs a feed writing newline delimited JSON to w, check Err for failed writes
+func NewJSONFeed(w io.Writer) *JSONFeed {
+	return &JSONFeed{
+		w:         w,
+		marshaler: &jsonpb.Marshaler{},
+	}
+}
+
+// Push writes the entry together with its type name, entries that aren't protobuf messages are skipped
+func (f *JSONFeed) Push(entry interface{}) {
+	pb, ok := entry.(proto.Message)
+	if !ok {
+		return
+	}
+
+	message, err := f.marshaler.MarshalToString(pb)
+	if err != nil {
+		f.setErr(err)
+		return
+	}
+	line, err := json.Marshal(&jsonFeedEntry{
+		Type:    reflect.TypeOf(pb).Elem().Name(),
+		Message: json.RawMessage(message),
+	})
+	if err != nil {
+		f.setErr(err)
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	_, err = f.w.Write(append(line, '\n'))
+	if err != nil {
+		f.err = err
+	}
+}
+
+// Err returns the last error that occurred while writing an entry
+func (f *JSONFeed) Err() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.err
+}
+
+func (f *JSONFeed) setErr(err error) {
+	f.mu.Lock()
+	f.err = err
+	f.mu.Unlock()
+}
+
+// ChannelFeed is a feed that passes the pushed messages on to a buffered channel.
+// When the buffer is full, messages are dropped unless the feed is set to block
+type ChannelFeed struct {
+	entries  chan proto.Message
+	blocking int32
+	dropped  uint64
+}
+
+// NewChannelFeed constructs a feed with a channel of the given buffer size, the returned channel receives the messages
+func NewChannelFeed(buffer int) (*ChannelFeed, <-chan proto.Message) {
+	entries := make(chan proto.Message, buffer)
+	return &ChannelFeed{entries: entries}, entries
+}
+
+// SetBlocking sets whether Push waits for room in the buffer instead of dropping the message,
+// a blocking feed stalls the calls of the session until the messages are consumed
+func (f *ChannelFeed) SetBlocking(blocking bool) {
+	var value int32
+	if blocking {
+		value = 1
+	}
+	atomic.StoreInt32(&f.blocking, value)
+}
+
+// Push puts the entry on to the channel, entries that aren't protobuf messages are skipped
+func (f *ChannelFeed) Push(entry interface{}) {
+	pb, ok := entry.(proto.Message)
+	if !ok {
+		return
+	}
+
+	if atomic.LoadInt32(&f.blocking) == 1 {
+		f.entries <- pb
+		return
+	}
+
+	select {
+	case f.entries <- pb:
+	default:
+		atomic.AddUint64(&f.dropped, 1)
+	}
+}
+
+// Dropped returns the number of messages dropped because the buffer was full
+func (f *ChannelFeed) Dropped() uint64 {
+	return atomic.LoadUint64(&f.dropped)
+}
+
+// FilterFeed is a feed that only passes on messages of certain types
+type FilterFeed struct {
+	inner Feed
+	types map[reflect.Type]bool
+}
+
+// NewFilterFeed constructs a feed forwarding the messages of the same concrete type as one of the exemplars to inner
+func NewFilterFeed(inner Feed, types ...proto.Message) *FilterFeed {
+	f := &FilterFeed{
+		inner: inner,
+		types: make(map[reflect.Type]bool),
+	}
+	for _, t := range types {
+		f.types[reflect.TypeOf(t)] = true
+	}
+	return f
+}
+
+// Push forwards the entry if its type matches
+func (f *FilterFeed) Push(entry interface{}) {
+	if f.types[reflect.TypeOf(entry)] {
+		f.inner.Push(entry)
+	}
+}
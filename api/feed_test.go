@@ -0,0 +1,39 @@
+package api
+
+import (
+	"testing"
+	"time"
+
+	protos "github.com/pogodevorg/POGOProtos-go"
+)
+
+// recordingFeed keeps every entry pushed to it
+type recordingFeed struct {
+	entries []interface{}
+}
+
+func (f *recordingFeed) Push(entry interface{}) {
+	f.entries = append(f.entries, entry)
+}
+
+func TestTimestampedMapFeedKeepsMapObjects(t *testing.T) {
+	s, _, _ := newTestSession(okResponse)
+	feed := &recordingFeed{}
+	s.feed = feed
+	s.SetTimestampedMapFeed(true)
+	captured := time.Now()
+
+	mapObjects := &protos.GetMapObjectsResponse{}
+	s.pushMap(mapObjects, captured)
+
+	if len(feed.entries) != 2 {
+		t.Fatalf("pushMap pushed %d entries, expected 2", len(feed.entries))
+	}
+	if feed.entries[0] != mapObjects {
+		t.Errorf("The first entry is %v, expected the map objects", feed.entries[0])
+	}
+	timestamped, ok := feed.entries[1].(*TimestampedMap)
+	if !ok || timestamped.MapObjects != mapObjects || !timestamped.Captured.Equal(captured) {
+		t.Errorf("The second entry is %v, expected the timestamped map objects", feed.entries[1])
+	}
+}
@@ -0,0 +1,46 @@
+package api
+
+import (
+	"time"
+
+	protos "github.com/pogodevorg/POGOProtos-go"
+)
+
+// defaultMsSinceLastLocationFix is reported until the first location fix has been made
+const defaultMsSinceLastLocationFix = 989
+
+// maxLocationFixes is the number of location fixes kept until they are sent along with the next signature
+const maxLocationFixes = 10
+
+// recordLocationFix marks the current location as a new location fix
+func (s *Session) recordLocationFix(now time.Time) {
+	s.lastFix = now
+	fix := &protos.Signature_LocationFix{
+		Provider:           "fused",
+		TimestampSnapshot:  getTimestamp(now) - getTimestamp(s.started),
+		Latitude:           float32(s.location.Lat),
+		Longitude:          float32(s.location.Lon),
+		Altitude:           float32(s.location.Alt),
+		HorizontalAccuracy: float32(s.location.Accuracy),
+		ProviderStatus:     3,
+		LocationType:       1,
+	}
+	s.locationFixes = append(s.locationFixes, fix)
+	if len(s.locationFixes) > maxLocationFixes {
+		s.locationFixes = s.locationFixes[len(s.locationFixes)-maxLocationFixes:]
+	}
+}
+
+// takeLocationFixes returns the location fixes made since the last signature
+func (s *Session) takeLocationFixes() []*protos.Signature_LocationFix {
+	fixes := s.locationFixes
+	s.locationFixes = nil
+	return fixes
+}
+
+func (s *Session) getMsSinceLastLocationFix(now time.Time) int64 {
+	if s.lastFix.IsZero() {
+		return defaultMsSinceLastLocationFix
+	}
+	return int64(now.Sub(s.lastFix) / time.Millisecond)
+}
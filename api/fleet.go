@@ -0,0 +1,44 @@
+package api
+
+import (
+	"sync"
+
+	"golang.org/x/net/context"
+)
+
+// InitAll logs in the sessions with at most concurrency logins at the same time, proxyAssign gives
+// the proxy for the session at index i. The errors are returned in the order of the sessions, sessions
+// that were not started before the context was done get the error of the context
+func InitAll(ctx context.Context, sessions []*Session, concurrency int, proxyAssign func(i int) int64) []error {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	errs := make([]error, len(sessions))
+	slots := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+
+	for i, session := range sessions {
+		select {
+		case slots <- struct{}{}:
+		case <-ctx.Done():
+			for j := i; j < len(sessions); j++ {
+				errs[j] = ctx.Err()
+			}
+			wg.Wait()
+			return errs
+		}
+
+		wg.Add(1)
+		go func(i int, session *Session) {
+			defer func() {
+				<-slots
+				wg.Done()
+			}()
+			errs[i] = session.Init(ctx, proxyAssign(i))
+		}(i, session)
+	}
+
+	wg.Wait()
+	return errs
+}
@@ -0,0 +1,74 @@
+package api
+
+import (
+	"strings"
+	"time"
+
+	protos "github.com/pogodevorg/POGOProtos-go"
+)
+
+// fortCooldown is the time a fort can't be searched again after it was searched
+const fortCooldown = 5 * time.Minute
+
+// fortCooldownKey returns the key of a fort in the cooldowns, which is the same for every spelling of its id
+func fortCooldownKey(fortID string) string {
+	return strings.ToLower(strings.TrimSpace(fortID))
+}
+
+// FortCooldownRemaining returns how long it takes until the fort can be searched again
+func (s *Session) FortCooldownRemaining(fortID string) time.Duration {
+	key := fortCooldownKey(fortID)
+	s.callMu.Lock()
+	defer s.callMu.Unlock()
+	until, ok := s.fortCooldowns[key]
+	if !ok {
+		return 0
+	}
+	remaining := time.Until(until)
+	if remaining <= 0 {
+		delete(s.fortCooldowns, key)
+		return 0
+	}
+	return remaining
+}
+
+// trackFortCooldown remembers until when a searched fort is in its cooldown
+func (s *Session) trackFortCooldown(fortID string, fortSearch *protos.FortSearchResponse) {
+	if fortSearch.Result != protos.FortSearchResponse_SUCCESS && fortSearch.Result != protos.FortSearchResponse_IN_COOLDOWN_PERIOD {
+		return
+	}
+	until := time.Now().Add(fortCooldown)
+	if fortSearch.CooldownCompleteTimestampMs > 0 {
+		until = time.Unix(0, fortSearch.CooldownCompleteTimestampMs*int64(time.Millisecond))
+	}
+	s.callMu.Lock()
+	s.fortCooldowns[fortCooldownKey(fortID)] = until
+	s.callMu.Unlock()
+}
+
+// SetTrackItemsGained sets whether the session keeps a running total of the items awarded by FortSearch
+func (s *Session) SetTrackItemsGained(track bool) {
+	s.trackItems = track
+}
+
+// ItemsGained returns the items awarded by FortSearch since item tracking was enabled
+func (s *Session) ItemsGained() map[protos.ItemId]int32 {
+	gained := make(map[protos.ItemId]int32, len(s.itemsGained))
+	for itemID, count := range s.itemsGained {
+		gained[itemID] = count
+	}
+	return gained
+}
+
+// trackItemsGained adds the items awarded by a fort search to the running total
+func (s *Session) trackItemsGained(items []*protos.ItemAward) {
+	if !s.trackItems {
+		return
+	}
+	if s.itemsGained == nil {
+		s.itemsGained = make(map[protos.ItemId]int32)
+	}
+	for _, item := range items {
+		s.itemsGained[item.ItemId] += item.ItemCount
+	}
+}
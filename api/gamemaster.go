@@ -0,0 +1,133 @@
+package api
+
+import (
+	"errors"
+
+	"github.com/golang/protobuf/proto"
+	"golang.org/x/net/context"
+
+	protos "github.com/pogodevorg/POGOProtos-go"
+)
+
+// GameMaster holds the item templates of the game indexed by id
+type GameMaster struct {
+	// Timestamp is the timestamp of the item templates in milliseconds
+	Timestamp uint64
+	Templates []*protos.DownloadItemTemplatesResponse_ItemTemplate
+
+	pokemon map[protos.PokemonId]*protos.PokemonSettings
+	moves   map[protos.PokemonMove]*protos.MoveSettings
+	items   map[protos.ItemId]*protos.ItemSettings
+}
+
+// NewGameMaster indexes the item templates of the response
+func NewGameMaster(templates *protos.DownloadItemTemplatesResponse) *GameMaster {
+	gm := &GameMaster{
+		Timestamp: templates.TimestampMs,
+		Templates: templates.ItemTemplates,
+		pokemon:   make(map[protos.PokemonId]*protos.PokemonSettings),
+		moves:     make(map[protos.PokemonMove]*protos.MoveSettings),
+		items:     make(map[protos.ItemId]*protos.ItemSettings),
+	}
+	for _, template := range templates.ItemTemplates {
+		if template.PokemonSettings != nil {
+			gm.pokemon[template.PokemonSettings.PokemonId] = template.PokemonSettings
+		}
+		if template.MoveSettings != nil {
+			gm.moves[template.MoveSettings.MovementId] = template.MoveSettings
+		}
+		if template.ItemSettings != nil {
+			gm.items[template.ItemSettings.ItemId] = template.ItemSettings
+		}
+	}
+	return gm
+}
+
+// Pokemon returns the settings of a pokémon
+func (gm *GameMaster) Pokemon(id protos.PokemonId) (*protos.PokemonSettings, bool) {
+	settings, ok := gm.pokemon[id]
+	return settings, ok
+}
+
+// Move returns the settings of a move
+func (gm *GameMaster) Move(id protos.PokemonMove) (*protos.MoveSettings, bool) {
+	settings, ok := gm.moves[id]
+	return settings, ok
+}
+
+// Item returns the settings of an item
+func (gm *GameMaster) Item(id protos.ItemId) (*protos.ItemSettings, bool) {
+	settings, ok := gm.items[id]
+	return settings, ok
+}
+
+// DownloadItemTemplates returns the item templates of the game, all of them are sent in a single response
+func (s *Session) DownloadItemTemplates(ctx context.Context, proxyId int64) (*protos.DownloadItemTemplatesResponse, error) {
+	requestMessage, err := proto.Marshal(&protos.DownloadItemTemplatesMessage{})
+	if err != nil {
+		return nil, ErrFormatting
+	}
+
+	requests := []*protos.Request{{RequestType: protos.RequestType_DOWNLOAD_ITEM_TEMPLATES, RequestMessage: requestMessage}}
+	response, err := s.Call(ctx, requests, proxyId)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(response.Returns) < 1 {
+		return nil, errors.New("Empty response")
+	}
+
+	templates := &protos.DownloadItemTemplatesResponse{}
+	err = unmarshalChecked(response.Returns[0], templates)
+	if err != nil {
+		return nil, err
+	}
+	s.feed.Push(templates)
+	s.debugProtoMessage("response return[0]", templates)
+
+	return templates, newErrCall(requests, response, GetErrorFromStatus(response.StatusCode))
+}
+
+// cacheRemoteConfig keeps the item templates timestamp of the remote config return of a call, if there is one
+func (s *Session) cacheRemoteConfig(requests []*protos.Request, response *protos.ResponseEnvelope) {
+	remoteConfigReturn, ok := getReturn(requests, response, protos.RequestType_DOWNLOAD_REMOTE_CONFIG_VERSION)
+	if !ok {
+		return
+	}
+	remoteConfig := &protos.DownloadRemoteConfigVersionResponse{}
+	if proto.Unmarshal(remoteConfigReturn, remoteConfig) == nil {
+		s.itemTemplatesTimestamp = remoteConfig.ItemTemplatesTimestampMs
+		s.remoteConfigSeen = true
+	}
+}
+
+// LoadGameMaster returns the game master of the session. Once loaded, the templates are only downloaded again
+// when the remote config reports a newer template timestamp. The remote config is only requested when no call
+// received it since the last LoadGameMaster, add DOWNLOAD_REMOTE_CONFIG_VERSION to SetCommonRequests to receive
+// it with Init and Announce
+func (s *Session) LoadGameMaster(ctx context.Context, proxyId int64) (*GameMaster, error) {
+	if s.gameMaster != nil {
+		if !s.remoteConfigSeen {
+			_, err := s.DownloadRemoteConfigVersion(ctx, proxyId)
+			if err != nil {
+				return nil, err
+			}
+		}
+		s.remoteConfigSeen = false
+		if s.itemTemplatesTimestamp <= s.gameMaster.Timestamp {
+			return s.gameMaster, nil
+		}
+	}
+	return s.ReloadGameMaster(ctx, proxyId)
+}
+
+// ReloadGameMaster downloads the item templates and replaces the game master of the session with them
+func (s *Session) ReloadGameMaster(ctx context.Context, proxyId int64) (*GameMaster, error) {
+	templates, err := s.DownloadItemTemplates(ctx, proxyId)
+	if err != nil {
+		return nil, err
+	}
+	s.gameMaster = NewGameMaster(templates)
+	return s.gameMaster, nil
+}
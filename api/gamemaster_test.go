@@ -0,0 +1,68 @@
+package api
+
+import (
+	"testing"
+
+	"golang.org/x/net/context"
+)
+
+func TestLoadGameMasterUsesKnownTimestamp(t *testing.T) {
+	s, transport, _ := newTestSession(okResponse)
+	s.setURL("pgorelease.example/plfe/1")
+	s.setTicket(testTicket())
+	s.gameMaster = &GameMaster{Timestamp: 1000}
+
+	// A call received the remote config already
+	s.itemTemplatesTimestamp = 1000
+	s.remoteConfigSeen = true
+	gm, err := s.LoadGameMaster(context.Background(), -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if gm != s.gameMaster {
+		t.Error("LoadGameMaster replaced a game master that is up to date")
+	}
+	if transport.requestCount() != 0 {
+		t.Errorf("LoadGameMaster sent %d requests for an up to date game master, expected none", transport.requestCount())
+	}
+
+	s.itemTemplatesTimestamp = 2000
+	s.remoteConfigSeen = true
+	_, err = s.LoadGameMaster(context.Background(), -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if transport.requestCount() != 1 {
+		t.Errorf("LoadGameMaster sent %d requests after the templates changed, expected 1", transport.requestCount())
+	}
+}
+
+func TestLoadGameMasterRequestsRemoteConfig(t *testing.T) {
+	s, transport, _ := newTestSession(okResponse)
+	s.setURL("pgorelease.example/plfe/1")
+	s.setTicket(testTicket())
+	s.gameMaster = &GameMaster{Timestamp: 1000}
+
+	_, err := s.LoadGameMaster(context.Background(), -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if transport.requestCount() != 1 {
+		t.Fatalf("LoadGameMaster sent %d requests, expected the remote config request only", transport.requestCount())
+	}
+	if len(transport.lastEnvelope().Requests) != 1 || transport.lastEnvelope().Requests[0].RequestType.String() != "DOWNLOAD_REMOTE_CONFIG_VERSION" {
+		t.Errorf("LoadGameMaster sent %v, expected the remote config request", transport.lastEnvelope().Requests)
+	}
+}
+
+func TestInitSendsTheCommonRequestsOnly(t *testing.T) {
+	s, transport, _ := newTestSession(okResponse)
+	s.Init(context.Background(), -1)
+
+	if transport.requestCount() != 1 {
+		t.Fatalf("Init sent %d requests, expected 1", transport.requestCount())
+	}
+	if len(transport.lastEnvelope().Requests) != len(DefaultCommonRequests) {
+		t.Errorf("Init sent %d requests in its envelope, expected the %d common requests", len(transport.lastEnvelope().Requests), len(DefaultCommonRequests))
+	}
+}
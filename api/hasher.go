@@ -0,0 +1,146 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+
+	"golang.org/x/net/context"
+	"golang.org/x/net/context/ctxhttp"
+
+	"github.com/muxgo/pgoapi-go/newcrypto"
+)
+
+// Hashes contains the values of the request signature that are derived from the request
+type Hashes struct {
+	LocationHash1 uint32
+	LocationHash2 uint32
+	RequestHash   []uint64
+	Unknown25     int64
+}
+
+// Hasher computes the hashes of the request signature
+type Hasher interface {
+	Hash(ctx context.Context, version uint64, timestamp uint64, location *Location, authTicket, sessionHash []byte, requests [][]byte) (*Hashes, error)
+}
+
+// signerHasher computes the hashes locally, it only supports the default API version
+type signerHasher struct {
+	signer *newcrypto.PogoSignature
+}
+
+func (h *signerHasher) Hash(ctx context.Context, version uint64, timestamp uint64, location *Location, authTicket, sessionHash []byte, requests [][]byte) (*Hashes, error) {
+	if h.signer == nil {
+		return nil, ErrNoSigner
+	}
+	if version != DefaultAPIVersion {
+		return nil, ErrUnsupportedAPIVersion
+	}
+
+	requestHash := make([]uint64, len(requests))
+	for idx, request := range requests {
+		requestHash[idx] = h.signer.HashRequest(authTicket, request)
+	}
+
+	return &Hashes{
+		LocationHash1: h.signer.HashLocation1(authTicket, location.Lat, location.Lon, location.Alt),
+		LocationHash2: h.signer.HashLocation2(location.Lat, location.Lon, location.Alt),
+		RequestHash:   requestHash,
+		Unknown25:     h.signer.Hash25(),
+	}, nil
+}
+
+type hashServerRequest struct {
+	Timestamp   uint64
+	Latitude    float64
+	Longitude   float64
+	Altitude    float64
+	AuthTicket  []byte
+	SessionData []byte
+	Requests    [][]byte
+}
+
+type hashServerResponse struct {
+	LocationAuthHash int32   `json:"locationAuthHash"`
+	LocationHash     int32   `json:"locationHash"`
+	RequestHashes    []int64 `json:"requestHashes"`
+}
+
+// HashServer computes the hashes through an external hashing server
+type HashServer struct {
+	endpoint  string
+	apiKey    string
+	unknown25 int64
+	http      *http.Client
+}
+
+// NewHashServer constructs a hashing server client, unknown25 is the signature constant
+// belonging to the API version the hashing server is used for
+func NewHashServer(endpoint, apiKey string, unknown25 int64) *HashServer {
+	return &HashServer{
+		endpoint:  endpoint,
+		apiKey:    apiKey,
+		unknown25: unknown25,
+		http:      &http.Client{},
+	}
+}
+
+// Hash requests the hashes from the hashing server
+func (h *HashServer) Hash(ctx context.Context, version uint64, timestamp uint64, location *Location, authTicket, sessionHash []byte, requests [][]byte) (*Hashes, error) {
+	body, err := json.Marshal(&hashServerRequest{
+		Timestamp:   timestamp,
+		Latitude:    location.Lat,
+		Longitude:   location.Lon,
+		Altitude:    location.Alt,
+		AuthTicket:  authTicket,
+		SessionData: sessionHash,
+		Requests:    requests,
+	})
+	if err != nil {
+		return nil, ErrFormatting
+	}
+
+	request, err := http.NewRequest("POST", h.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return nil, ErrFormatting
+	}
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("X-AuthToken", h.apiKey)
+	request.Header.Set("X-Version", strconv.FormatUint(version, 10))
+
+	response, err := ctxhttp.Do(ctx, h.http, request)
+	if err != nil {
+		return nil, fmt.Errorf("hashing server: %s", err)
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != 200 {
+		return nil, fmt.Errorf("hashing server: Status code was %d, expected 200", response.StatusCode)
+	}
+
+	responseBytes, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return nil, fmt.Errorf("hashing server: %s", err)
+	}
+
+	hashResponse := &hashServerResponse{}
+	err = json.Unmarshal(responseBytes, hashResponse)
+	if err != nil {
+		return nil, &ErrResponse{err}
+	}
+
+	requestHash := make([]uint64, len(hashResponse.RequestHashes))
+	for idx, hash := range hashResponse.RequestHashes {
+		requestHash[idx] = uint64(hash)
+	}
+
+	return &Hashes{
+		LocationHash1: uint32(hashResponse.LocationAuthHash),
+		LocationHash2: uint32(hashResponse.LocationHash),
+		RequestHash:   requestHash,
+		Unknown25:     h.unknown25,
+	}, nil
+}
@@ -0,0 +1,48 @@
+package api
+
+import (
+	"strings"
+)
+
+func isHex(str string) bool {
+	if str == "" {
+		return false
+	}
+	for _, c := range str {
+		if !(c >= '0' && c <= '9' || c >= 'a' && c <= 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+func isDigits(str string) bool {
+	if str == "" {
+		return false
+	}
+	for _, c := range str {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// normalizeSpawnID lower cases a spawn point id, which is the hex token of an s2 cell
+func normalizeSpawnID(spawnID string) (string, error) {
+	spawnID = strings.ToLower(strings.TrimSpace(spawnID))
+	if len(spawnID) > 16 || !isHex(spawnID) {
+		return "", ErrFormatting
+	}
+	return spawnID, nil
+}
+
+// normalizeFortID lower cases a fort id, which is a hex identifier followed by a dot and a number
+func normalizeFortID(fortID string) (string, error) {
+	fortID = strings.ToLower(strings.TrimSpace(fortID))
+	parts := strings.Split(fortID, ".")
+	if len(parts) != 2 || !isHex(parts[0]) || !isDigits(parts[1]) {
+		return "", ErrFormatting
+	}
+	return fortID, nil
+}
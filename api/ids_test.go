@@ -0,0 +1,50 @@
+package api
+
+import (
+	"testing"
+)
+
+func TestNormalizeSpawnID(t *testing.T) {
+	tests := []struct {
+		id       string
+		expected string
+		err      error
+	}{
+		{"47c3a7e8b1d", "47c3a7e8b1d", nil},
+		{"47C3A7E8B1D", "47c3a7e8b1d", nil},
+		{" 47c3a7e8b1d\n", "47c3a7e8b1d", nil},
+		{"", "", ErrFormatting},
+		{"47c3a7e8b1dz", "", ErrFormatting},
+		{"47c3a7e8b1d0000000", "", ErrFormatting},
+	}
+
+	for _, test := range tests {
+		id, err := normalizeSpawnID(test.id)
+		if id != test.expected || err != test.err {
+			t.Errorf("normalizeSpawnID(%q) = %q, %v, expected %q, %v", test.id, id, err, test.expected, test.err)
+		}
+	}
+}
+
+func TestNormalizeFortID(t *testing.T) {
+	tests := []struct {
+		id       string
+		expected string
+		err      error
+	}{
+		{"e4a5b5f9a1b24f5c9a6f2b0b7f1a8e3c.16", "e4a5b5f9a1b24f5c9a6f2b0b7f1a8e3c.16", nil},
+		{"E4A5B5F9A1B24F5C9A6F2B0B7F1A8E3C.16", "e4a5b5f9a1b24f5c9a6f2b0b7f1a8e3c.16", nil},
+		{" e4a5b5f9a1b24f5c9a6f2b0b7f1a8e3c.11 ", "e4a5b5f9a1b24f5c9a6f2b0b7f1a8e3c.11", nil},
+		{"e4a5b5f9a1b24f5c9a6f2b0b7f1a8e3c", "", ErrFormatting},
+		{"e4a5b5f9a1b24f5c9a6f2b0b7f1a8e3c.1a", "", ErrFormatting},
+		{"not-a-fort.16", "", ErrFormatting},
+		{"", "", ErrFormatting},
+	}
+
+	for _, test := range tests {
+		id, err := normalizeFortID(test.id)
+		if id != test.expected || err != test.err {
+			t.Errorf("normalizeFortID(%q) = %q, %v, expected %q, %v", test.id, id, err, test.expected, test.err)
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package api
+
+import (
+	protos "github.com/pogodevorg/POGOProtos-go"
+)
+
+// InventoryView is a typed view on the flat list of inventory items
+type InventoryView struct {
+	Pokemon       []*protos.PokemonData
+	Eggs          []*protos.PokemonData
+	Items         map[protos.ItemId]int32
+	Candy         map[protos.PokemonFamilyId]int32
+	EggIncubators []*protos.EggIncubator
+	PlayerStats   *protos.PlayerStats
+}
+
+// NewInventoryView sorts the inventory items of the response by kind
+func NewInventoryView(inventory *protos.GetInventoryResponse) *InventoryView {
+	view := &InventoryView{
+		Items: make(map[protos.ItemId]int32),
+		Candy: make(map[protos.PokemonFamilyId]int32),
+	}
+	eachInventoryItem(inventory, func(data *protos.InventoryItemData) {
+		if data.PokemonData != nil {
+			if data.PokemonData.IsEgg {
+				view.Eggs = append(view.Eggs, data.PokemonData)
+			} else {
+				view.Pokemon = append(view.Pokemon, data.PokemonData)
+			}
+		}
+		if data.Item != nil {
+			view.Items[data.Item.ItemId] += data.Item.Count
+		}
+		if data.Candy != nil {
+			view.Candy[data.Candy.FamilyId] += data.Candy.Candy
+		}
+		if data.EggIncubators != nil {
+			view.EggIncubators = append(view.EggIncubators, data.EggIncubators.EggIncubator...)
+		}
+		if data.PlayerStats != nil {
+			view.PlayerStats = data.PlayerStats
+		}
+	})
+	return view
+}
+
+func eachInventoryItem(inventory *protos.GetInventoryResponse, fn func(data *protos.InventoryItemData)) {
+	if inventory == nil || inventory.InventoryDelta == nil {
+		return
+	}
+	for _, inventoryItem := range inventory.InventoryDelta.InventoryItems {
+		if inventoryItem.InventoryItemData != nil {
+			fn(inventoryItem.InventoryItemData)
+		}
+	}
+}
+
+// ItemCounts returns the number of items held for each item id in the inventory
+func ItemCounts(inventory *protos.GetInventoryResponse) map[protos.ItemId]int32 {
+	return NewInventoryView(inventory).Items
+}
+
+// PlayerLevel returns the level and experience of the player from the player stats in the inventory,
+// ok is false when the inventory doesn't contain player stats
+func PlayerLevel(inventory *protos.GetInventoryResponse) (level int32, xp int64, ok bool) {
+	stats := NewInventoryView(inventory).PlayerStats
+	if stats == nil {
+		return 0, 0, false
+	}
+	return stats.Level, stats.Experience, true
+}
@@ -0,0 +1,31 @@
+package api
+
+import (
+	"time"
+)
+
+// defaultJitter is the fraction by which the pauses of the helpers vary by default
+const defaultJitter = 0.2
+
+// jitter varies base by up to pct of it in either direction, r in [0, 1) picks where in that range it ends up
+func jitter(base time.Duration, pct float64, r float64) time.Duration {
+	if pct <= 0 || base <= 0 {
+		return base
+	}
+	if pct > 1 {
+		pct = 1
+	}
+	spread := float64(base) * pct
+	return base + time.Duration(spread*(2*r-1))
+}
+
+// SetJitter sets the fraction, between 0 and 1, by which the pauses the helpers take between their requests vary,
+// higher values make the timing less regular. Zero makes the pauses fixed
+func (s *Session) SetJitter(pct float64) {
+	s.jitterPct = pct
+}
+
+// pause returns the pause of base varied by the jitter of the session
+func (s *Session) pause(base time.Duration) time.Duration {
+	return jitter(base, s.jitterPct, s.randFloat64())
+}
@@ -0,0 +1,89 @@
+package api
+
+import (
+	"log"
+	"sync"
+	"time"
+)
+
+// latencyWeight is the weight of a new response time in the rolling baseline of a proxy
+const latencyWeight = 0.1
+
+// latencyAnomalyFactor is how many times faster or slower than the baseline a response has to be to be reported
+const latencyAnomalyFactor = 4.0
+
+// latencyMinSamples is the number of responses needed before the baseline of a proxy is trusted
+const latencyMinSamples = 10
+
+type latencyBaseline struct {
+	average time.Duration
+	samples int
+}
+
+// LatencyAnomalyFunc is called with the response time of a request through a proxy that is far off the baseline
+// of the proxy, a proxy that suddenly responds much faster or slower is often intercepting the requests or dying
+type LatencyAnomalyFunc func(proxyId int64, latency, baseline time.Duration)
+
+// logLatencyAnomaly is the default LatencyAnomalyFunc, it writes a warning to the standard logger
+func logLatencyAnomaly(proxyId int64, latency, baseline time.Duration) {
+	log.Printf("rpc/client: Response through proxy %d took %s, the baseline is %s", proxyId, latency, baseline)
+}
+
+// latencyTracker keeps a rolling response time baseline per proxy and reports responses far off it
+type latencyTracker struct {
+	mu        sync.Mutex
+	baselines map[int64]*latencyBaseline
+	onAnomaly LatencyAnomalyFunc
+}
+
+func newLatencyTracker() *latencyTracker {
+	return &latencyTracker{
+		baselines: make(map[int64]*latencyBaseline),
+		onAnomaly: logLatencyAnomaly,
+	}
+}
+
+func (t *latencyTracker) setAnomalyFunc(fn LatencyAnomalyFunc) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.onAnomaly = fn
+}
+
+func (t *latencyTracker) observe(proxyId int64, latency time.Duration) {
+	t.mu.Lock()
+	baseline, ok := t.baselines[proxyId]
+	if !ok {
+		t.baselines[proxyId] = &latencyBaseline{average: latency, samples: 1}
+		t.mu.Unlock()
+		return
+	}
+
+	anomaly := false
+	average := baseline.average
+	if baseline.samples >= latencyMinSamples {
+		ratio := float64(latency) / float64(average)
+		anomaly = ratio > latencyAnomalyFactor || ratio < 1/latencyAnomalyFactor
+	}
+	baseline.average += time.Duration(latencyWeight * float64(latency-baseline.average))
+	baseline.samples++
+	onAnomaly := t.onAnomaly
+	t.mu.Unlock()
+
+	// The callback is called without holding the lock, so it may use the RPC client
+	if anomaly && onAnomaly != nil {
+		onAnomaly(proxyId, latency, average)
+	}
+}
+
+// LatencyBaseline returns the rolling average response time of the requests through the proxy,
+// ok is false when no request has been sent through the proxy yet
+func (c *RPC) LatencyBaseline(proxyId int64) (baseline time.Duration, ok bool) {
+	c.latency.mu.Lock()
+	defer c.latency.mu.Unlock()
+
+	b, ok := c.latency.baselines[proxyId]
+	if !ok {
+		return 0, false
+	}
+	return b.average, true
+}
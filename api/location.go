@@ -2,7 +2,10 @@ package api
 
 import (
 	"encoding/binary"
+	"errors"
+	"fmt"
 	"math"
+	"strings"
 
 	"github.com/golang/geo/s2"
 	protos "github.com/pogodevorg/POGOProtos-go"
@@ -26,6 +29,54 @@ type Location struct {
 	Accuracy float64
 }
 
+// defaultAccuracy is the horizontal accuracy in meters of locations that don't come from a location fix
+const defaultAccuracy = 5.0
+
+const geohashAlphabet = "0123456789bcdefghjkmnpqrstuvwxyz"
+
+// LocationFromGeohash decodes a base-32 geohash of any precision to the location at the center of its cell
+func LocationFromGeohash(geohash string) (*Location, error) {
+	if geohash == "" {
+		return nil, errors.New("Empty geohash")
+	}
+
+	minLat, maxLat := -90.0, 90.0
+	minLon, maxLon := -180.0, 180.0
+	even := true
+	for _, c := range strings.ToLower(geohash) {
+		value := strings.IndexRune(geohashAlphabet, c)
+		if value < 0 {
+			return nil, fmt.Errorf("Invalid geohash character %q", c)
+		}
+		for bit := 4; bit >= 0; bit-- {
+			set := value&(1<<uint(bit)) != 0
+			// The bits alternate between longitude and latitude, starting with longitude
+			if even {
+				mid := (minLon + maxLon) / 2
+				if set {
+					minLon = mid
+				} else {
+					maxLon = mid
+				}
+			} else {
+				mid := (minLat + maxLat) / 2
+				if set {
+					minLat = mid
+				} else {
+					maxLat = mid
+				}
+			}
+			even = !even
+		}
+	}
+
+	return &Location{
+		Lat:      (minLat + maxLat) / 2,
+		Lon:      (minLon + maxLon) / 2,
+		Accuracy: defaultAccuracy,
+	}, nil
+}
+
 func (l *Location) GetCellIDs() CellIDs {
 	origin := s2.CellIDFromLatLng(s2.LatLngFromDegrees(l.Lat, l.Lon)).Parent(cellIDLevel)
 
@@ -38,16 +89,53 @@ func (l *Location) GetCellIDs() CellIDs {
 	return cellIDs
 }
 
+// CellIDsForBounds returns the cells of the given level covering the bounding box
+func CellIDsForBounds(minLat, minLng, maxLat, maxLng float64, level int) []uint64 {
+	rect := boundsRect(minLat, minLng, maxLat, maxLng)
+	coverer := &s2.RegionCoverer{
+		MinLevel: level,
+		MaxLevel: level,
+		MaxCells: math.MaxInt32,
+	}
+
+	covering := coverer.Covering(rect)
+	cellIDs := make([]uint64, len(covering))
+	for idx, cellID := range covering {
+		cellIDs[idx] = uint64(cellID)
+	}
+	return cellIDs
+}
+
+// MinCellsForBounds returns the least number of cells of the given level the bounding box can be covered with,
+// it is computed from the area of the bounding box so it is cheap for any size of bounding box
+func MinCellsForBounds(minLat, minLng, maxLat, maxLng float64, level int) float64 {
+	return boundsRect(minLat, minLng, maxLat, maxLng).Area() / s2.MaxAreaMetric.Value(level)
+}
+
+func boundsRect(minLat, minLng, maxLat, maxLng float64) s2.Rect {
+	return s2.RectFromLatLng(s2.LatLngFromDegrees(minLat, minLng)).AddPoint(s2.LatLngFromDegrees(maxLat, maxLng))
+}
+
 // DistanceToFort returns distance between the location and a fort using the Haversine formula
-// Reference: https://gist.github.com/cdipaolo/d3f8db3848278b49db68
 func (l *Location) DistanceToFort(fort *protos.FortData) float64 {
+	return distance(l.Lat, l.Lon, fort.Latitude, fort.Longitude)
+}
+
+// DistanceTo returns distance between the location and another location using the Haversine formula
+func (l *Location) DistanceTo(other *Location) float64 {
+	return distance(l.Lat, l.Lon, other.Lat, other.Lon)
+}
+
+// distance returns the distance in meters between two coordinates using the Haversine formula
+// Reference: https://gist.github.com/cdipaolo/d3f8db3848278b49db68
+func distance(lat1, lon1, lat2, lon2 float64) float64 {
 	// convert to radians
 	// must cast radius as float to multiply later
 	var la1, lo1, la2, lo2 float64
-	la1 = l.Lat * math.Pi / 180
-	lo1 = l.Lon * math.Pi / 180
-	la2 = fort.Latitude * math.Pi / 180
-	lo2 = fort.Longitude * math.Pi / 180
+	la1 = lat1 * math.Pi / 180
+	lo1 = lon1 * math.Pi / 180
+	la2 = lat2 * math.Pi / 180
+	lo2 = lon2 * math.Pi / 180
 
 	// calculate
 	dla := math.Sin(0.5 * (la2 - la1))
@@ -65,3 +153,15 @@ func (l *Location) GetBytes() []byte {
 	binary.BigEndian.PutUint64(b[16:24], math.Float64bits(l.Accuracy))
 	return b[:24]
 }
+
+// accuracyModel samples the horizontal accuracy reported with every request
+type accuracyModel struct {
+	min float64
+	max float64
+}
+
+// sample returns an accuracy between min and max for r in [0, 1), skewed towards min
+// as most fixes are good with the occasional worse one in between
+func (m *accuracyModel) sample(r float64) float64 {
+	return m.min + (m.max-m.min)*r*r
+}
@@ -0,0 +1,780 @@
+package api
+
+import (
+	"errors"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"golang.org/x/net/context"
+
+	protos "github.com/pogodevorg/POGOProtos-go"
+)
+
+// mapBatchInterval is the time between the map requests of a batched scan
+const mapBatchInterval = time.Second
+
+// mapBatchSize is the number of cells requested at once by a bounding box scan
+const mapBatchSize = 25
+
+// maxTimeTillHidden is the largest TimeTillHiddenMs the remote service reports reliably
+const maxTimeTillHidden = time.Hour
+
+// minSpawnDuration is the shortest time a spawned pokémon stays on the map
+const minSpawnDuration = 15 * time.Minute
+
+// defaultMaxCells is the largest number of cells requested at once, larger requests are refused by the remote service
+const defaultMaxCells = 100
+
+// maxScanCells is the largest number of cells ScanBounds requests, a larger bounding box gives ErrTooManyCells
+const maxScanCells = 2500
+
+// SetShuffleCells makes Announce send its cell ids in a shuffled order instead of the sorted order,
+// the order is drawn from a random source seeded with seed so runs can be reproduced
+func (s *Session) SetShuffleCells(enabled bool, seed int64) {
+	if !enabled {
+		s.cellShuffle = nil
+		return
+	}
+	s.cellShuffle = rand.New(rand.NewSource(seed))
+}
+
+// shuffleCells shuffles the cell ids in place and keeps the timestamps aligned with their cells
+func shuffleCells(r *rand.Rand, cellIDs []uint64, timestamps []int64) {
+	for i := len(cellIDs) - 1; i > 0; i-- {
+		j := r.Intn(i + 1)
+		cellIDs[i], cellIDs[j] = cellIDs[j], cellIDs[i]
+		timestamps[i], timestamps[j] = timestamps[j], timestamps[i]
+	}
+}
+
+// SetMaxCells sets the largest number of cells a single map request may contain, requests with more cells
+// give ErrTooManyCells without being sent. GetMapObjectsBatched and ScanBounds should be used for larger areas.
+// The limit can only be lowered, a limit above the 100 cells the remote service accepts is clamped to it
+func (s *Session) SetMaxCells(max int) {
+	if max > defaultMaxCells {
+		max = defaultMaxCells
+	}
+	s.maxCells = max
+}
+
+// cellLimit returns the largest number of cells a single map request may contain
+func (s *Session) cellLimit() int {
+	if s.maxCells <= 0 {
+		return defaultMaxCells
+	}
+	return s.maxCells
+}
+
+func (s *Session) checkCellCount(cellIDs []uint64) error {
+	if len(cellIDs) > s.cellLimit() {
+		return ErrTooManyCells
+	}
+	return nil
+}
+
+// TimestampedMap is pushed to the feed after the map objects of Announce when SetTimestampedMapFeed is enabled
+type TimestampedMap struct {
+	// Captured is the time the map objects were received
+	Captured   time.Time
+	MapObjects *protos.GetMapObjectsResponse
+}
+
+// SetTimestampedMapFeed makes Announce push a TimestampedMap to the feed after the map objects. It isn't a protobuf
+// message, so feeds that only pass on protobuf messages, like JSONFeed and ChannelFeed, still get the map objects alone
+func (s *Session) SetTimestampedMapFeed(timestamped bool) {
+	s.timestampedMapFeed = timestamped
+}
+
+// LastMapTime returns the time the last map objects of Announce were received, or the zero time if there are none yet
+func (s *Session) LastMapTime() time.Time {
+	s.callMu.Lock()
+	defer s.callMu.Unlock()
+	return s.lastMapTime
+}
+
+// pushMap pushes the map objects to the feed, followed by them wrapped with the time they were received when enabled
+func (s *Session) pushMap(mapObjects *protos.GetMapObjectsResponse, captured time.Time) {
+	s.feed.Push(mapObjects)
+	if s.timestampedMapFeed {
+		s.feed.Push(&TimestampedMap{Captured: captured, MapObjects: mapObjects})
+	}
+}
+
+// SetIncrementalMap makes Announce send the timestamp of the last response for every cell it requested before,
+// so the remote service only returns what changed since then
+func (s *Session) SetIncrementalMap(incremental bool) {
+	s.incrementalMap = incremental
+}
+
+// ResetMapTimestamps forgets the cell timestamps, the next incremental Announce requests the cells in full
+func (s *Session) ResetMapTimestamps() {
+	s.callMu.Lock()
+	s.cellTimestamps = nil
+	s.callMu.Unlock()
+}
+
+// mapTimestamps returns the timestamps to request the cells with, it is called with callMu held
+func (s *Session) mapTimestamps(cellIDs []uint64) []int64 {
+	timestamps := make([]int64, len(cellIDs))
+	if s.incrementalMap {
+		for i, cellID := range cellIDs {
+			timestamps[i] = s.cellTimestamps[cellID]
+		}
+	}
+	return timestamps
+}
+
+// trackMapTimestamps remembers the timestamps of the cells in the response for the next incremental request,
+// it is called with callMu held
+func (s *Session) trackMapTimestamps(mapObjects *protos.GetMapObjectsResponse) {
+	if !s.incrementalMap {
+		return
+	}
+	if s.cellTimestamps == nil {
+		s.cellTimestamps = make(map[uint64]int64)
+	}
+	for _, cell := range mapObjects.MapCells {
+		if cell.CurrentTimestampMs > 0 {
+			s.cellTimestamps[cell.S2CellId] = cell.CurrentTimestampMs
+		}
+	}
+}
+
+// SetChangedCellsFeed makes Announce push only the cells that changed since the previous Announce to the feed,
+// the full view stays available through MapCells
+func (s *Session) SetChangedCellsFeed(changedOnly bool) {
+	s.changedCellsFeed = changedOnly
+}
+
+// MapCells returns a copy of the latest version of every cell Announce has seen, ordered by cell id
+func (s *Session) MapCells() []*protos.MapCell {
+	s.callMu.Lock()
+	defer s.callMu.Unlock()
+	cells := make([]*protos.MapCell, 0, len(s.cellCache))
+	for _, cached := range s.cellCache {
+		cells = append(cells, proto.Clone(cached.cell).(*protos.MapCell))
+	}
+	sort.Slice(cells, func(i, j int) bool {
+		return cells[i].S2CellId < cells[j].S2CellId
+	})
+	return cells
+}
+
+// ResetMapCells forgets the cached cells, the next Announce sees all of its cells as changed
+func (s *Session) ResetMapCells() {
+	s.callMu.Lock()
+	defer s.callMu.Unlock()
+	s.cellCache = nil
+}
+
+// maxCachedCells is the number of cells the cell cache keeps, the cells seen longest ago are evicted first
+const maxCachedCells = maxScanCells
+
+// cachedCell is the merged view of a cell over all the responses it was in
+type cachedCell struct {
+	cell *protos.MapCell
+	seen time.Time
+	// despawns holds when the wild pokémon of the cell despawn, for the ones without a reliable despawn time
+	// it is estimated from when they were first seen
+	despawns map[uint64]time.Time
+}
+
+// mergeMapCells merges the map objects of the response cells into the cached cells and returns copies of the cached
+// cells that changed, it is called with the call lock held. The response has to be merged before it is filtered,
+// so the cache holds every map object
+func (s *Session) mergeMapCells(mapObjects *protos.GetMapObjectsResponse) []*protos.MapCell {
+	if s.cellCache == nil {
+		s.cellCache = make(map[uint64]*cachedCell)
+	}
+	now := time.Now()
+	changed := make([]*protos.MapCell, 0, len(mapObjects.MapCells))
+	for _, cell := range mapObjects.MapCells {
+		cached, ok := s.cellCache[cell.S2CellId]
+		if !ok {
+			cached = &cachedCell{
+				cell:     &protos.MapCell{S2CellId: cell.S2CellId},
+				despawns: make(map[uint64]time.Time),
+			}
+			s.cellCache[cell.S2CellId] = cached
+		}
+		cached.seen = now
+		if mergeCell(cached, cell, now) || !ok {
+			// The cached cell changes with the next response, the feed gets a copy
+			changed = append(changed, proto.Clone(cached.cell).(*protos.MapCell))
+		}
+	}
+	s.evictMapCells()
+	return changed
+}
+
+// evictMapCells drops the cells seen longest ago until the cache is within maxCachedCells
+func (s *Session) evictMapCells() {
+	for len(s.cellCache) > maxCachedCells {
+		var oldest *cachedCell
+		for _, cached := range s.cellCache {
+			if oldest == nil || cached.seen.Before(oldest.seen) {
+				oldest = cached
+			}
+		}
+		delete(s.cellCache, oldest.cell.S2CellId)
+	}
+}
+
+// mergeCell merges the map objects of a response cell into the cached cell by id and reports whether the cached cell
+// changed. As an incremental response only carries what changed, forts and spawn points stay until the response lists
+// them as deleted and wild and catchable pokémon stay until they despawn. Nearby pokémon have no despawn time, they stay
+// until the cell is seen with pokémon but without them
+func mergeCell(cachedCell *cachedCell, cell *protos.MapCell, now time.Time) bool {
+	cached := cachedCell.cell
+	changed := false
+	if cell.CurrentTimestampMs > cached.CurrentTimestampMs {
+		cached.CurrentTimestampMs = cell.CurrentTimestampMs
+	}
+	cached.IsTruncatedList = cell.IsTruncatedList
+
+	for _, fort := range cell.Forts {
+		i := 0
+		for i < len(cached.Forts) && cached.Forts[i].Id != fort.Id {
+			i++
+		}
+		if i == len(cached.Forts) {
+			cached.Forts = append(cached.Forts, fort)
+			changed = true
+		} else if !proto.Equal(cached.Forts[i], fort) {
+			cached.Forts[i] = fort
+			changed = true
+		}
+	}
+	if len(cell.DeletedObjects) > 0 {
+		deleted := make(map[string]bool, len(cell.DeletedObjects))
+		for _, id := range cell.DeletedObjects {
+			deleted[id] = true
+		}
+		forts := cached.Forts[:0]
+		for _, fort := range cached.Forts {
+			if deleted[fort.Id] {
+				changed = true
+				continue
+			}
+			forts = append(forts, fort)
+		}
+		cached.Forts = forts
+	}
+
+	var added bool
+	cached.SpawnPoints, added = mergeSpawnPoints(cached.SpawnPoints, cell.SpawnPoints)
+	changed = changed || added
+	cached.DecimatedSpawnPoints, added = mergeSpawnPoints(cached.DecimatedSpawnPoints, cell.DecimatedSpawnPoints)
+	changed = changed || added
+
+	for _, wild := range cell.WildPokemons {
+		i := 0
+		for i < len(cached.WildPokemons) && cached.WildPokemons[i].EncounterId != wild.EncounterId {
+			i++
+		}
+		despawn, reliable := DespawnTime(wild, now)
+		if i == len(cached.WildPokemons) {
+			cached.WildPokemons = append(cached.WildPokemons, wild)
+			cachedCell.despawns[wild.EncounterId] = despawn
+			changed = true
+		} else if !proto.Equal(cached.WildPokemons[i], wild) {
+			cached.WildPokemons[i] = wild
+			changed = true
+		}
+		// An estimate would move along with every response, only a reliable despawn time replaces the first one
+		if reliable {
+			cachedCell.despawns[wild.EncounterId] = despawn
+		}
+	}
+	wilds := cached.WildPokemons[:0]
+	for _, wild := range cached.WildPokemons {
+		if cachedCell.despawns[wild.EncounterId].Before(now) {
+			delete(cachedCell.despawns, wild.EncounterId)
+			changed = true
+			continue
+		}
+		wilds = append(wilds, wild)
+	}
+	cached.WildPokemons = wilds
+
+	for _, catchable := range cell.CatchablePokemons {
+		i := 0
+		for i < len(cached.CatchablePokemons) && cached.CatchablePokemons[i].EncounterId != catchable.EncounterId {
+			i++
+		}
+		if i == len(cached.CatchablePokemons) {
+			cached.CatchablePokemons = append(cached.CatchablePokemons, catchable)
+			changed = true
+		} else if !proto.Equal(cached.CatchablePokemons[i], catchable) {
+			cached.CatchablePokemons[i] = catchable
+			changed = true
+		}
+	}
+	nowMs := now.UnixNano() / int64(time.Millisecond)
+	catchables := cached.CatchablePokemons[:0]
+	for _, catchable := range cached.CatchablePokemons {
+		if catchable.ExpirationTimestampMs > 0 && catchable.ExpirationTimestampMs < nowMs {
+			changed = true
+			continue
+		}
+		catchables = append(catchables, catchable)
+	}
+	cached.CatchablePokemons = catchables
+
+	if len(cell.WildPokemons) > 0 || len(cell.CatchablePokemons) > 0 || len(cell.NearbyPokemons) > 0 {
+		if len(cached.NearbyPokemons) != len(cell.NearbyPokemons) {
+			changed = true
+		} else {
+			for i, nearby := range cell.NearbyPokemons {
+				if !proto.Equal(cached.NearbyPokemons[i], nearby) {
+					changed = true
+					break
+				}
+			}
+		}
+		cached.NearbyPokemons = append(cached.NearbyPokemons[:0], cell.NearbyPokemons...)
+	}
+
+	return changed
+}
+
+// mergeSpawnPoints adds the spawn points that aren't cached yet, spawn points are identified by their position
+func mergeSpawnPoints(cached, spawnPoints []*protos.SpawnPoint) ([]*protos.SpawnPoint, bool) {
+	added := false
+	for _, spawnPoint := range spawnPoints {
+		known := false
+		for _, c := range cached {
+			if c.Latitude == spawnPoint.Latitude && c.Longitude == spawnPoint.Longitude {
+				known = true
+				break
+			}
+		}
+		if !known {
+			cached = append(cached, spawnPoint)
+			added = true
+		}
+	}
+	return cached, added
+}
+
+// MapOption changes what the map helpers return
+type MapOption func(*mapOptions)
+
+type mapOptions struct {
+	fortTypes       map[protos.FortType]bool
+	emptyMapRetry   time.Duration
+	stripEmptyCells bool
+	overThreshold   *bool
+}
+
+// WithStripEmptyCells makes the map helpers remove the map cells without any map objects from the response
+func WithStripEmptyCells() MapOption {
+	return func(o *mapOptions) {
+		o.stripEmptyCells = true
+	}
+}
+
+// WithOverThreshold makes the map helpers report in over whether the remote service truncated any of the cells,
+// which means fewer cells should be requested at once
+func WithOverThreshold(over *bool) MapOption {
+	return func(o *mapOptions) {
+		o.overThreshold = over
+	}
+}
+
+// WithEmptyMapRetry makes Announce request the map once more after the delay when it came back
+// without any forts or wild pokémon, which happens when the remote service hasn't warmed up the cells yet
+func WithEmptyMapRetry(delay time.Duration) MapOption {
+	return func(o *mapOptions) {
+		o.emptyMapRetry = delay
+	}
+}
+
+// WithFortTypes makes the map helpers only return the forts of the given types, like only gyms
+func WithFortTypes(fortTypes ...protos.FortType) MapOption {
+	return func(o *mapOptions) {
+		if o.fortTypes == nil {
+			o.fortTypes = make(map[protos.FortType]bool)
+		}
+		for _, fortType := range fortTypes {
+			o.fortTypes[fortType] = true
+		}
+	}
+}
+
+func newMapOptions(options []MapOption) *mapOptions {
+	o := &mapOptions{}
+	for _, option := range options {
+		option(o)
+	}
+	return o
+}
+
+// apply strips the map objects that were not asked for from the response
+func (o *mapOptions) apply(mapObjects *protos.GetMapObjectsResponse) {
+	if o.overThreshold != nil {
+		*o.overThreshold = IsOverThreshold(mapObjects)
+	}
+	o.filter(mapObjects)
+}
+
+// filter drops the forts and cells the options leave out
+func (o *mapOptions) filter(mapObjects *protos.GetMapObjectsResponse) {
+	if o.fortTypes != nil {
+		for _, cell := range mapObjects.MapCells {
+			forts := cell.Forts[:0]
+			for _, fort := range cell.Forts {
+				if o.fortTypes[fort.Type] {
+					forts = append(forts, fort)
+				}
+			}
+			cell.Forts = forts
+		}
+	}
+	if o.stripEmptyCells {
+		StripEmptyCells(mapObjects)
+	}
+}
+
+// IsEmptyCell checks whether a map cell carries no map objects at all
+func IsEmptyCell(cell *protos.MapCell) bool {
+	return len(cell.Forts) == 0 &&
+		len(cell.SpawnPoints) == 0 &&
+		len(cell.DecimatedSpawnPoints) == 0 &&
+		len(cell.WildPokemons) == 0 &&
+		len(cell.CatchablePokemons) == 0 &&
+		len(cell.NearbyPokemons) == 0 &&
+		len(cell.DeletedObjects) == 0
+}
+
+// IsEmptyMap checks whether none of the cells contain forts or wild pokémon
+func IsEmptyMap(mapObjects *protos.GetMapObjectsResponse) bool {
+	for _, cell := range mapObjects.MapCells {
+		if len(cell.Forts) > 0 || len(cell.WildPokemons) > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// StripEmptyCells removes the map cells without any map objects from the response
+func StripEmptyCells(mapObjects *protos.GetMapObjectsResponse) {
+	cells := mapObjects.MapCells[:0]
+	for _, cell := range mapObjects.MapCells {
+		if !IsEmptyCell(cell) {
+			cells = append(cells, cell)
+		}
+	}
+	mapObjects.MapCells = cells
+}
+
+// IsOverThreshold checks whether the remote service truncated any of the cells,
+// which happens when more cells or objects were requested than it is willing to return
+func IsOverThreshold(mapObjects *protos.GetMapObjectsResponse) bool {
+	for _, cell := range mapObjects.MapCells {
+		if cell.IsTruncatedList {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Session) getMapObjects(ctx context.Context, cellIDs []uint64, proxyId int64) (*protos.GetMapObjectsResponse, error) {
+	err := s.checkCellCount(cellIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	location := s.getLocation()
+	requestMessage, err := proto.Marshal(&protos.GetMapObjectsMessage{
+		CellId:           cellIDs,
+		SinceTimestampMs: make([]int64, len(cellIDs)),
+		Longitude:        location.Lon,
+		Latitude:         location.Lat,
+	})
+	if err != nil {
+		return nil, ErrFormatting
+	}
+
+	requests := []*protos.Request{{RequestType: protos.RequestType_GET_MAP_OBJECTS, RequestMessage: requestMessage}}
+	response, err := s.Call(ctx, requests, proxyId)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(response.Returns) < 1 {
+		return nil, errors.New("Empty response")
+	}
+
+	mapObjects := &protos.GetMapObjectsResponse{}
+	err = unmarshalChecked(response.Returns[0], mapObjects)
+	if err != nil {
+		return nil, err
+	}
+	s.debugProtoMessage("response return[0]", mapObjects)
+
+	return mapObjects, newErrCall(requests, response, GetErrorFromStatus(response.StatusCode))
+}
+
+// GetMapObjects returns the map objects of the given cells
+func (s *Session) GetMapObjects(ctx context.Context, cellIDs []uint64, proxyId int64, options ...MapOption) (*protos.GetMapObjectsResponse, error) {
+	mapObjects, err := s.getMapObjects(ctx, cellIDs, proxyId)
+	if mapObjects != nil {
+		newMapOptions(options).apply(mapObjects)
+		s.feed.Push(mapObjects)
+	}
+	return mapObjects, err
+}
+
+// GetMapObjectsBatched splits the cells in batches of at most batchSize cells, requests them one after another
+// and merges the map cells of all batches in to one response. A batch size above the cell limit of SetMaxCells
+// is lowered to it
+func (s *Session) GetMapObjectsBatched(ctx context.Context, cellIDs []uint64, batchSize int, proxyId int64, options ...MapOption) (*protos.GetMapObjectsResponse, error) {
+	if batchSize < 1 {
+		return nil, ErrFormatting
+	}
+	if limit := s.cellLimit(); batchSize > limit {
+		batchSize = limit
+	}
+
+	seen := make(map[uint64]bool)
+	unique := make([]uint64, 0, len(cellIDs))
+	for _, cellID := range cellIDs {
+		if !seen[cellID] {
+			seen[cellID] = true
+			unique = append(unique, cellID)
+		}
+	}
+
+	var batches [][]uint64
+	for start := 0; start < len(unique); start += batchSize {
+		end := start + batchSize
+		if end > len(unique) {
+			end = len(unique)
+		}
+		batches = append(batches, unique[start:end])
+	}
+
+	results := make([]*protos.GetMapObjectsResponse, len(batches))
+	if s.mapConcurrency > 1 {
+		err := s.getMapObjectsConcurrently(ctx, batches, results, proxyId)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		for i, batch := range batches {
+			if i > 0 {
+				select {
+				case <-time.After(s.pause(mapBatchInterval)):
+				case <-ctx.Done():
+					return nil, ctx.Err()
+				}
+			}
+
+			mapObjects, err := s.getMapObjects(ctx, batch, proxyId)
+			if err != nil {
+				return nil, err
+			}
+			results[i] = mapObjects
+		}
+	}
+
+	// The results are merged in the order of the batches, regardless of the order they came in
+	merged := &protos.GetMapObjectsResponse{}
+	merger := newCellMerger(merged)
+	for _, mapObjects := range results {
+		merger.merge(mapObjects)
+	}
+
+	newMapOptions(options).apply(merged)
+	s.feed.Push(merged)
+
+	return merged, nil
+}
+
+// SetMapConcurrency sets the number of batches GetMapObjectsBatched and ScanBounds request at the same time,
+// the batches are requested one after the other with a pause in between by default. The limit of requests
+// in flight per proxy of the RPC options still applies
+func (s *Session) SetMapConcurrency(concurrency int) {
+	s.mapConcurrency = concurrency
+}
+
+// getMapObjectsConcurrently requests the batches with at most mapConcurrency requests at the same time,
+// every result is stored at the index of its batch
+func (s *Session) getMapObjectsConcurrently(ctx context.Context, batches [][]uint64, results []*protos.GetMapObjectsResponse, proxyId int64) error {
+	// The first failed batch cancels the others, like an errgroup does, and its error is the one reported
+	batchCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var failOnce sync.Once
+	var firstErr error
+	slots := make(chan struct{}, s.mapConcurrency)
+	var wg sync.WaitGroup
+
+launch:
+	for i, batch := range batches {
+		select {
+		case slots <- struct{}{}:
+		case <-batchCtx.Done():
+			break launch
+		}
+
+		wg.Add(1)
+		go func(i int, batch []uint64) {
+			defer func() {
+				<-slots
+				wg.Done()
+			}()
+			mapObjects, err := s.getMapObjects(batchCtx, batch, proxyId)
+			if err != nil {
+				failOnce.Do(func() {
+					firstErr = err
+					cancel()
+				})
+				return
+			}
+			results[i] = mapObjects
+		}(i, batch)
+	}
+
+	wg.Wait()
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return firstErr
+}
+
+// cellMerger merges the map cells of several responses, keeping one entry per cell
+type cellMerger struct {
+	target *protos.GetMapObjectsResponse
+	seen   map[uint64]bool
+}
+
+func newCellMerger(target *protos.GetMapObjectsResponse) *cellMerger {
+	return &cellMerger{
+		target: target,
+		seen:   make(map[uint64]bool),
+	}
+}
+
+func (m *cellMerger) merge(mapObjects *protos.GetMapObjectsResponse) {
+	m.target.Status = mapObjects.Status
+	for _, cell := range mapObjects.MapCells {
+		if m.seen[cell.S2CellId] {
+			continue
+		}
+		m.seen[cell.S2CellId] = true
+		m.target.MapCells = append(m.target.MapCells, cell)
+	}
+}
+
+// DespawnTime returns a best effort estimate of when a wild pokémon disappears,
+// the boolean tells whether the estimate is based on a reliable TimeTillHiddenMs.
+// When it isn't, the pokémon is assumed to stay for the shortest spawn duration since it was last modified
+func DespawnTime(wild *protos.WildPokemon, now time.Time) (time.Time, bool) {
+	seen := now
+	if wild.LastModifiedTimestampMs > 0 {
+		seen = time.Unix(0, wild.LastModifiedTimestampMs*int64(time.Millisecond))
+	}
+
+	timeTillHidden := time.Duration(wild.TimeTillHiddenMs) * time.Millisecond
+	if timeTillHidden > 0 && timeTillHidden <= maxTimeTillHidden {
+		return seen.Add(timeTillHidden), true
+	}
+
+	estimate := seen.Add(minSpawnDuration)
+	if estimate.Before(now) {
+		estimate = now
+	}
+	return estimate, false
+}
+
+// ScanBounds returns the map objects of all the cells covering the bounding box,
+// a bounding box covered by more than maxScanCells cells gives ErrTooManyCells without sending a request
+func (s *Session) ScanBounds(ctx context.Context, minLat, minLng, maxLat, maxLng float64, proxyId int64, options ...MapOption) (*protos.GetMapObjectsResponse, error) {
+	// The covering of a large bounding box would take millions of cells, it is rejected by its area first
+	if MinCellsForBounds(minLat, minLng, maxLat, maxLng, cellIDLevel) > maxScanCells {
+		return nil, ErrTooManyCells
+	}
+	cellIDs := CellIDsForBounds(minLat, minLng, maxLat, maxLng, cellIDLevel)
+	if len(cellIDs) > maxScanCells {
+		return nil, ErrTooManyCells
+	}
+	return s.GetMapObjectsBatched(ctx, cellIDs, mapBatchSize, proxyId, options...)
+}
+
+// MapPokemonEntry is a pokémon on the map, merged from the wild, catchable and nearby pokémon of the map cells
+type MapPokemonEntry struct {
+	EncounterID  uint64
+	SpawnPointID string
+	PokemonID    protos.PokemonId
+	Latitude     float64
+	Longitude    float64
+	// Despawn is the estimated time the pokémon disappears, DespawnReliable tells whether the remote service reported it
+	Despawn         time.Time
+	DespawnReliable bool
+	// Nearby is set for pokémon that are only known to be nearby, they have no position
+	Nearby bool
+}
+
+// FlattenPokemon lists the pokémon of all the map cells once per encounter id, the wild pokémon go first
+// as they carry the most details, the catchable and nearby pokémon fill in what is missing
+func FlattenPokemon(mapObjects *protos.GetMapObjectsResponse) []MapPokemonEntry {
+	var entries []MapPokemonEntry
+	if mapObjects == nil {
+		return entries
+	}
+
+	now := time.Now()
+	index := make(map[uint64]int)
+	entry := func(encounterID uint64) *MapPokemonEntry {
+		if idx, ok := index[encounterID]; ok {
+			return &entries[idx]
+		}
+		index[encounterID] = len(entries)
+		entries = append(entries, MapPokemonEntry{EncounterID: encounterID, Nearby: true})
+		return &entries[len(entries)-1]
+	}
+
+	for _, cell := range mapObjects.MapCells {
+		for _, wild := range cell.WildPokemons {
+			e := entry(wild.EncounterId)
+			e.SpawnPointID = wild.SpawnPointId
+			if wild.PokemonData != nil {
+				e.PokemonID = wild.PokemonData.PokemonId
+			}
+			e.Latitude, e.Longitude, e.Nearby = wild.Latitude, wild.Longitude, false
+			e.Despawn, e.DespawnReliable = DespawnTime(wild, now)
+		}
+	}
+	for _, cell := range mapObjects.MapCells {
+		for _, catchable := range cell.CatchablePokemons {
+			e := entry(catchable.EncounterId)
+			if e.SpawnPointID == "" {
+				e.SpawnPointID = catchable.SpawnPointId
+			}
+			if e.PokemonID == 0 {
+				e.PokemonID = catchable.PokemonId
+			}
+			if e.Nearby {
+				e.Latitude, e.Longitude, e.Nearby = catchable.Latitude, catchable.Longitude, false
+			}
+			if !e.DespawnReliable && catchable.ExpirationTimestampMs > 0 {
+				e.Despawn = time.Unix(0, catchable.ExpirationTimestampMs*int64(time.Millisecond))
+				e.DespawnReliable = true
+			}
+		}
+	}
+	for _, cell := range mapObjects.MapCells {
+		for _, nearby := range cell.NearbyPokemons {
+			e := entry(nearby.EncounterId)
+			if e.PokemonID == 0 {
+				e.PokemonID = nearby.PokemonId
+			}
+		}
+	}
+	return entries
+}
@@ -0,0 +1,260 @@
+package api
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"golang.org/x/net/context"
+
+	protos "github.com/pogodevorg/POGOProtos-go"
+)
+
+func TestFlattenPokemon(t *testing.T) {
+	lastModified := getTimestamp(time.Now())
+	mapObjects := &protos.GetMapObjectsResponse{
+		MapCells: []*protos.MapCell{
+			{
+				WildPokemons: []*protos.WildPokemon{{
+					EncounterId:             1,
+					SpawnPointId:            "spawn1",
+					Latitude:                51.5,
+					Longitude:               -0.1,
+					LastModifiedTimestampMs: int64(lastModified),
+					TimeTillHiddenMs:        600000,
+					PokemonData:             &protos.PokemonData{PokemonId: protos.PokemonId_PIDGEY},
+				}},
+				CatchablePokemons: []*protos.MapPokemon{{
+					EncounterId:  1,
+					SpawnPointId: "other",
+					PokemonId:    protos.PokemonId_RATTATA,
+					Latitude:     1,
+					Longitude:    1,
+				}},
+				NearbyPokemons: []*protos.NearbyPokemon{{EncounterId: 1, PokemonId: protos.PokemonId_RATTATA}},
+			},
+			{
+				// The same wild pokémon is seen in a neighbouring cell as well
+				WildPokemons: []*protos.WildPokemon{{
+					EncounterId:  1,
+					SpawnPointId: "spawn1",
+					Latitude:     51.5,
+					Longitude:    -0.1,
+					PokemonData:  &protos.PokemonData{PokemonId: protos.PokemonId_PIDGEY},
+				}},
+				CatchablePokemons: []*protos.MapPokemon{{
+					EncounterId:           2,
+					SpawnPointId:          "spawn2",
+					PokemonId:             protos.PokemonId_RATTATA,
+					Latitude:              51.6,
+					Longitude:             -0.2,
+					ExpirationTimestampMs: 1500000000000,
+				}},
+				NearbyPokemons: []*protos.NearbyPokemon{
+					{EncounterId: 2, PokemonId: protos.PokemonId_PIDGEY},
+					{EncounterId: 3, PokemonId: protos.PokemonId_CATERPIE},
+				},
+			},
+		},
+	}
+
+	entries := FlattenPokemon(mapObjects)
+	if len(entries) != 3 {
+		t.Fatalf("FlattenPokemon gave %d entries, expected 3: %+v", len(entries), entries)
+	}
+
+	wild := entries[0]
+	if wild.EncounterID != 1 || wild.PokemonID != protos.PokemonId_PIDGEY || wild.SpawnPointID != "spawn1" {
+		t.Errorf("The wild pokémon was not kept over its catchable and nearby entries: %+v", wild)
+	}
+	if wild.Latitude != 51.5 || wild.Longitude != -0.1 || wild.Nearby {
+		t.Errorf("The wild pokémon does not have its own position: %+v", wild)
+	}
+	if !wild.DespawnReliable {
+		t.Errorf("The despawn time of the wild pokémon is not reliable: %+v", wild)
+	}
+
+	catchable := entries[1]
+	if catchable.EncounterID != 2 || catchable.PokemonID != protos.PokemonId_RATTATA || catchable.SpawnPointID != "spawn2" {
+		t.Errorf("The catchable pokémon was not kept over its nearby entry: %+v", catchable)
+	}
+	if catchable.Latitude != 51.6 || catchable.Longitude != -0.2 || catchable.Nearby {
+		t.Errorf("The catchable pokémon does not have its own position: %+v", catchable)
+	}
+	if !catchable.DespawnReliable || !catchable.Despawn.Equal(time.Unix(1500000000, 0)) {
+		t.Errorf("The catchable pokémon does not despawn at its expiration: %+v", catchable)
+	}
+
+	nearby := entries[2]
+	if nearby.EncounterID != 3 || nearby.PokemonID != protos.PokemonId_CATERPIE || !nearby.Nearby {
+		t.Errorf("The nearby pokémon is not listed as nearby: %+v", nearby)
+	}
+}
+
+func TestMergeMapCells(t *testing.T) {
+	s, _, _ := newTestSession(okResponse)
+	fortA := &protos.FortData{Id: "a.16", Latitude: 51.5, Longitude: -0.1}
+	fortB := &protos.FortData{Id: "b.16", Latitude: 51.6, Longitude: -0.2}
+
+	changed := s.mergeMapCells(&protos.GetMapObjectsResponse{MapCells: []*protos.MapCell{
+		{S2CellId: 1, CurrentTimestampMs: 1000, Forts: []*protos.FortData{fortA}},
+	}})
+	if len(changed) != 1 {
+		t.Fatalf("The first response changed %d cells, expected 1", len(changed))
+	}
+
+	// An incremental response only carries the fort that is new
+	changed = s.mergeMapCells(&protos.GetMapObjectsResponse{MapCells: []*protos.MapCell{
+		{S2CellId: 1, CurrentTimestampMs: 2000, Forts: []*protos.FortData{fortB}},
+	}})
+	if len(changed) != 1 || len(changed[0].Forts) != 2 {
+		t.Fatalf("The incremental response did not add its fort to the cached one: %v", changed)
+	}
+
+	changed = s.mergeMapCells(&protos.GetMapObjectsResponse{MapCells: []*protos.MapCell{
+		{S2CellId: 1, CurrentTimestampMs: 3000},
+	}})
+	if len(changed) != 0 {
+		t.Errorf("A response without changes changed %d cells, expected none", len(changed))
+	}
+
+	changed = s.mergeMapCells(&protos.GetMapObjectsResponse{MapCells: []*protos.MapCell{
+		{S2CellId: 1, CurrentTimestampMs: 4000, DeletedObjects: []string{"a.16"}},
+	}})
+	if len(changed) != 1 || len(changed[0].Forts) != 1 || changed[0].Forts[0].Id != "b.16" {
+		t.Errorf("The deleted fort was not removed from the cached cell: %v", changed)
+	}
+}
+
+func TestMergeMapCellsEvictsOldestCells(t *testing.T) {
+	s, _, _ := newTestSession(okResponse)
+	for id := uint64(1); id <= maxCachedCells+1; id++ {
+		s.mergeMapCells(&protos.GetMapObjectsResponse{MapCells: []*protos.MapCell{{S2CellId: id}}})
+		s.cellCache[id].seen = time.Unix(int64(id), 0)
+	}
+	s.evictMapCells()
+
+	if len(s.cellCache) != maxCachedCells {
+		t.Fatalf("The cache holds %d cells, expected %d", len(s.cellCache), maxCachedCells)
+	}
+	if _, ok := s.cellCache[1]; ok {
+		t.Error("The cell seen longest ago was not evicted")
+	}
+}
+
+// errConnectionReset is the network error of the failing batch in TestConcurrentBatchFailureIsReported
+var errConnectionReset = errors.New("connection reset by peer")
+
+// batchTransport fails the map request of cell 2 and holds the other requests until they are cancelled
+type batchTransport struct{}
+
+func (batchTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	body, err := ioutil.ReadAll(req.Body)
+	if err != nil {
+		return nil, err
+	}
+	envelope := &protos.RequestEnvelope{}
+	err = proto.Unmarshal(body, envelope)
+	if err != nil {
+		return nil, err
+	}
+	message := &protos.GetMapObjectsMessage{}
+	err = proto.Unmarshal(envelope.Requests[0].RequestMessage, message)
+	if err != nil {
+		return nil, err
+	}
+	if len(message.CellId) > 0 && message.CellId[0] == 2 {
+		return nil, errConnectionReset
+	}
+	<-req.Context().Done()
+	return nil, req.Context().Err()
+}
+
+func TestConcurrentBatchFailureIsReported(t *testing.T) {
+	s, _, _ := newTestSession(okResponse)
+	s.rpc.http = &http.Client{Transport: batchTransport{}}
+	s.setURL("pgorelease.example/plfe/1")
+	s.setTicket(testTicket())
+	s.SetMapConcurrency(3)
+
+	batches := [][]uint64{{1}, {2}, {3}}
+	results := make([]*protos.GetMapObjectsResponse, len(batches))
+	err := s.getMapObjectsConcurrently(context.Background(), batches, results, -1)
+	if !errors.Is(err, errConnectionReset) {
+		t.Fatalf("The concurrent batches gave %v, expected the error of the failed batch", err)
+	}
+	if errors.Is(err, context.Canceled) {
+		t.Errorf("The concurrent batches gave the cancellation of another batch: %v", err)
+	}
+}
+
+func TestMergeMapCellsExpiresWildPokemon(t *testing.T) {
+	s, _, _ := newTestSession(okResponse)
+	s.mergeMapCells(&protos.GetMapObjectsResponse{MapCells: []*protos.MapCell{
+		{S2CellId: 1, WildPokemons: []*protos.WildPokemon{{EncounterId: 1}}},
+	}})
+
+	// Without a last modified timestamp the despawn is estimated from when the pokémon was first seen
+	s.cellCache[1].despawns[1] = time.Now().Add(-time.Second)
+	changed := s.mergeMapCells(&protos.GetMapObjectsResponse{MapCells: []*protos.MapCell{
+		{S2CellId: 1, WildPokemons: []*protos.WildPokemon{{EncounterId: 1}}},
+	}})
+	if len(changed) != 1 || len(changed[0].WildPokemons) != 0 {
+		t.Errorf("The despawned wild pokémon is still cached: %v", changed)
+	}
+}
+
+func TestMapCellsAreCopies(t *testing.T) {
+	s, _, _ := newTestSession(okResponse)
+	changed := s.mergeMapCells(&protos.GetMapObjectsResponse{MapCells: []*protos.MapCell{
+		{S2CellId: 1, Forts: []*protos.FortData{{Id: "a.16"}}},
+	}})
+	changed[0].Forts = nil
+	cells := s.MapCells()
+	if len(cells) != 1 || len(cells[0].Forts) != 1 {
+		t.Fatalf("Changing a changed cell changed the cache: %v", cells)
+	}
+	cells[0].Forts = nil
+	if len(s.MapCells()[0].Forts) != 1 {
+		t.Error("Changing a cell of MapCells changed the cache")
+	}
+}
+
+func TestScanBoundsRejectsLargeBounds(t *testing.T) {
+	s, transport, _ := newTestSession(okResponse)
+	s.setURL("pgorelease.example/plfe/1")
+	s.setTicket(testTicket())
+
+	// Europe, which would be covered by tens of millions of cells
+	_, err := s.ScanBounds(context.Background(), 35, -10, 70, 40, -1)
+	if err != ErrTooManyCells {
+		t.Fatalf("ScanBounds gave %v, expected %v", err, ErrTooManyCells)
+	}
+	if transport.requestCount() != 0 {
+		t.Errorf("ScanBounds sent %d requests, expected none", transport.requestCount())
+	}
+}
+
+func TestConcurrentMapRequestsAreSpreadOut(t *testing.T) {
+	s, _, _ := newTestSession(okResponse)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err := s.waitForMapRefresh(ctx)
+	if err != nil {
+		t.Fatalf("The first map request waited: %v", err)
+	}
+	first := s.lastMapRequest
+
+	// The second map request is due after the refresh time and gives up on the cancelled context
+	err = s.waitForMapRefresh(ctx)
+	if err != context.Canceled {
+		t.Fatalf("The second map request gave %v, expected %v", err, context.Canceled)
+	}
+	if !s.lastMapRequest.Equal(first.Add(defaultMapRefresh)) {
+		t.Errorf("The second map request reserved %v, expected %v", s.lastMapRequest, first.Add(defaultMapRefresh))
+	}
+}
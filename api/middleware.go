@@ -0,0 +1,27 @@
+package api
+
+import (
+	"golang.org/x/net/context"
+
+	protos "github.com/pogodevorg/POGOProtos-go"
+)
+
+// CallFunc performs a call with the given requests
+type CallFunc func(ctx context.Context, requests []*protos.Request, proxyId int64) (*protos.ResponseEnvelope, error)
+
+// CallMiddleware wraps a call, it can change the requests, the response or the error
+// and decides whether and how often the next call is made
+type CallMiddleware func(next CallFunc) CallFunc
+
+// Use adds middleware around Call, the middleware added first is the outermost
+func (s *Session) Use(middleware ...CallMiddleware) {
+	s.middleware = append(s.middleware, middleware...)
+}
+
+// chain wraps the call with the middleware of the session
+func (s *Session) chain(call CallFunc) CallFunc {
+	for i := len(s.middleware) - 1; i >= 0; i-- {
+		call = s.middleware[i](call)
+	}
+	return call
+}
@@ -0,0 +1,34 @@
+package api
+
+// moveGate keeps the map from being requested over and over from the same spot
+type moveGate struct {
+	minDistance   float64
+	sameSpotLimit int
+	last          *Location
+	sameSpot      int
+}
+
+// SetMinMapDistance makes Announce require the player to have moved at least the given number of meters
+// since the last Announce, sameSpotLimit is the number of requests allowed in a row without moving that far.
+// When the player hasn't moved ErrNotMoved is returned without sending a request, a zero distance disables the check
+func (s *Session) SetMinMapDistance(meters float64, sameSpotLimit int) {
+	s.moveGate = moveGate{minDistance: meters, sameSpotLimit: sameSpotLimit}
+}
+
+// check verifies the player moved far enough since the last map request and records the location
+func (g *moveGate) check(location *Location) error {
+	if g.minDistance <= 0 {
+		return nil
+	}
+	if g.last != nil && g.last.DistanceTo(location) < g.minDistance {
+		if g.sameSpot >= g.sameSpotLimit {
+			return ErrNotMoved
+		}
+		g.sameSpot++
+		return nil
+	}
+	last := *location
+	g.last = &last
+	g.sameSpot = 0
+	return nil
+}
@@ -0,0 +1,130 @@
+package api
+
+import (
+	"crypto/rand"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/golang/protobuf/jsonpb"
+	"github.com/muxgo/pgoapi-go/auth"
+	"github.com/muxgo/pgoapi-go/newcrypto"
+	protos "github.com/pogodevorg/POGOProtos-go"
+)
+
+// defaultDeviceInfo is the device the session identifies as in the request signature by default
+var defaultDeviceInfo = &protos.Signature_DeviceInfo{
+	DeviceId:             "<device_id>",
+	DeviceBrand:          "Apple",
+	DeviceModel:          "iPhone",
+	DeviceModelBoot:      "Iphone7,2",
+	HardwareManufacturer: "Apple",
+	HardwareModel:        "N66AP",
+	FirmwareBrand:        "iPhone OS",
+	FirmwareType:         "9.3.3",
+}
+
+// SessionOption configures a session constructed with NewSessionWithOptions
+type SessionOption func(*Session)
+
+// WithSigner sets the signer the requests are signed with
+func WithSigner(signer *newcrypto.PogoSignature) SessionOption {
+	return func(s *Session) {
+		s.signer = signer
+	}
+}
+
+// WithProvider sets the auth provider the session logs in with
+func WithProvider(provider auth.Provider) SessionOption {
+	return func(s *Session) {
+		s.provider = provider
+	}
+}
+
+// WithLocation sets the location the session starts at
+func WithLocation(location *Location) SessionOption {
+	return func(s *Session) {
+		s.location = location
+	}
+}
+
+// WithFeed sets the feed the responses are pushed to
+func WithFeed(feed Feed) SessionOption {
+	return func(s *Session) {
+		s.feed = feed
+	}
+}
+
+// WithDebug sets whether the requests and responses are logged
+func WithDebug(debug bool) SessionOption {
+	return func(s *Session) {
+		s.debug = debug
+	}
+}
+
+// WithLogger sets the logger the debug output and the latency warnings of the RPC client are written to,
+// the standard logger is used by default
+func WithLogger(logger *log.Logger) SessionOption {
+	return func(s *Session) {
+		s.logger = logger
+		s.rpc.latency.setAnomalyFunc(func(proxyId int64, latency, baseline time.Duration) {
+			logger.Printf("rpc/client: Response through proxy %d took %s, the baseline is %s", proxyId, latency, baseline)
+		})
+	}
+}
+
+// WithHTTPClient sets the http client the requests are sent with, requests through proxies
+// registered with RPC.SetProxy use their own transport
+func WithHTTPClient(client *http.Client) SessionOption {
+	return func(s *Session) {
+		s.rpc.http = client
+	}
+}
+
+// WithDeviceInfo sets the device the session identifies as in the request signature
+func WithDeviceInfo(deviceInfo *protos.Signature_DeviceInfo) SessionOption {
+	return func(s *Session) {
+		s.deviceInfo = deviceInfo
+	}
+}
+
+// WithRateLimit sets the minimum time between the calls of the session
+func WithRateLimit(interval time.Duration) SessionOption {
+	return func(s *Session) {
+		s.callInterval = interval
+	}
+}
+
+// WithRPCOptions sets the options of the RPC client of the session
+func WithRPCOptions(options RPCOptions) SessionOption {
+	return func(s *Session) {
+		s.rpc.SetOptions(options)
+	}
+}
+
+// NewSessionWithOptions constructs a Pokémon Go RPC API client configured by the options,
+// at least the provider and the location have to be set
+func NewSessionWithOptions(options ...SessionOption) *Session {
+	s := &Session{
+		rpc:       NewRPC(),
+		debugger:  &jsonpb.Marshaler{Indent: "\t"},
+		feed:      &VoidFeed{},
+		started:   time.Now(),
+		hasTicket: false,
+		hash:      make([]byte, 32),
+		random:    rand.Reader,
+
+		apiVersion: DefaultAPIVersion,
+
+		downloadSettingsHash: defaultDownloadSettingsHash,
+
+		announceCheckChallenge: true,
+		fortCooldowns:          make(map[string]time.Time),
+		jitterPct:              defaultJitter,
+		deviceInfo:             defaultDeviceInfo,
+	}
+	for _, option := range options {
+		option(s)
+	}
+	return s
+}
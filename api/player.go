@@ -0,0 +1,82 @@
+package api
+
+import (
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	protos "github.com/pogodevorg/POGOProtos-go"
+)
+
+// playerCache holds the last player response received by the session
+type playerCache struct {
+	ttl       time.Duration
+	player    *protos.GetPlayerResponse
+	fetchedAt time.Time
+}
+
+func (c *playerCache) set(player *protos.GetPlayerResponse) {
+	c.player = player
+	c.fetchedAt = time.Now()
+}
+
+// get returns the cached player response if it is still within the cache window
+func (c *playerCache) get() (*protos.GetPlayerResponse, bool) {
+	if c.ttl <= 0 || c.player == nil || time.Since(c.fetchedAt) > c.ttl {
+		return nil, false
+	}
+	return c.player, true
+}
+
+// SetPlayerCacheTTL sets how long GetPlayer returns the last received player data instead of requesting it again,
+// a zero duration disables the cache
+func (s *Session) SetPlayerCacheTTL(d time.Duration) {
+	s.playerCache.ttl = d
+}
+
+// cachePlayer stores the player return of a call, if there is one
+func (s *Session) cachePlayer(requests []*protos.Request, response *protos.ResponseEnvelope) {
+	playerReturn, ok := getReturn(requests, response, protos.RequestType_GET_PLAYER)
+	if !ok {
+		return
+	}
+	player := &protos.GetPlayerResponse{}
+	if proto.Unmarshal(playerReturn, player) == nil {
+		s.playerCache.set(player)
+	}
+}
+
+// completedTutorial lists the tutorial states an account that went through the whole tutorial has
+var completedTutorial = []protos.TutorialState{
+	protos.TutorialState_LEGAL_SCREEN,
+	protos.TutorialState_AVATAR_SELECTION,
+	protos.TutorialState_POKEMON_CAPTURE,
+	protos.TutorialState_NAME_SELECTION,
+	protos.TutorialState_FIRST_TIME_EXPERIENCE_COMPLETE,
+}
+
+// IsTutorialComplete checks whether the player went through every step of the tutorial
+func IsTutorialComplete(player *protos.GetPlayerResponse) bool {
+	if player == nil || player.PlayerData == nil {
+		return false
+	}
+	done := make(map[protos.TutorialState]bool, len(player.PlayerData.TutorialState))
+	for _, state := range player.PlayerData.TutorialState {
+		done[state] = true
+	}
+	for _, state := range completedTutorial {
+		if !done[state] {
+			return false
+		}
+	}
+	return true
+}
+
+// HasTeam checks whether the player has picked a team
+func HasTeam(player *protos.GetPlayerResponse) bool {
+	return player != nil && player.PlayerData != nil && player.PlayerData.Team != protos.TeamColor_NEUTRAL
+}
+
+// HasWarning checks whether the account has been warned by the remote service, which usually precedes a ban
+func HasWarning(player *protos.GetPlayerResponse) bool {
+	return player != nil && player.Warn
+}
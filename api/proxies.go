@@ -0,0 +1,139 @@
+package api
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+
+	"golang.org/x/net/context"
+	"golang.org/x/net/proxy"
+)
+
+// proxyRegistry maps proxy ids to the http clients sending requests through the proxies,
+// the clients are reused so their connections are kept alive between requests
+type proxyRegistry struct {
+	mu      sync.RWMutex
+	clients map[int64]*http.Client
+}
+
+func newProxyRegistry() *proxyRegistry {
+	return &proxyRegistry{
+		clients: make(map[int64]*http.Client),
+	}
+}
+
+// SetProxy makes the requests with the proxy id go through the proxy at proxyURL, http, https and socks5
+// proxy urls are supported. Requests with an id that isn't registered go through ProxyHost, or give
+// ErrUnknownProxy when ProxyHost isn't set
+func (c *RPC) SetProxy(id int64, proxyURL string) error {
+	u, err := url.Parse(proxyURL)
+	if err != nil {
+		return raise(fmt.Sprintf("Invalid proxy url: %s", err))
+	}
+
+	transport, err := newProxyTransport(u)
+	if err != nil {
+		return err
+	}
+
+	c.proxies.mu.Lock()
+	defer c.proxies.mu.Unlock()
+	c.proxies.clients[id] = &http.Client{
+		Jar:           c.http.Jar,
+		CheckRedirect: c.http.CheckRedirect,
+		Transport:     transport,
+	}
+	return nil
+}
+
+// newProxyTransport builds a transport for the proxy, the scheme of the url selects the kind of proxy.
+// The timeouts and connection pooling are the ones of the default transport
+func newProxyTransport(u *url.URL) (*http.Transport, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	switch u.Scheme {
+	case "http", "https":
+		transport.Proxy = http.ProxyURL(u)
+	case "socks5":
+		dialer, err := proxy.FromURL(u, proxy.Direct)
+		if err != nil {
+			return nil, raise(fmt.Sprintf("Invalid socks5 proxy: %s", err))
+		}
+		contextDialer, ok := dialer.(proxy.ContextDialer)
+		if !ok {
+			return nil, raise("The socks5 dialer does not support contexts")
+		}
+		// The connection to the proxy replaces the proxy settings of the environment
+		transport.Proxy = nil
+		transport.DialContext = contextDialer.DialContext
+	default:
+		return nil, raise(fmt.Sprintf("Unsupported proxy scheme %q", u.Scheme))
+	}
+	return transport, nil
+}
+
+// proxyClient returns the http client sending the requests through the registered proxy
+func (c *RPC) proxyClient(proxyId int64) (*http.Client, bool) {
+	c.proxies.mu.RLock()
+	defer c.proxies.mu.RUnlock()
+	client, ok := c.proxies.clients[proxyId]
+	return client, ok
+}
+
+// TLSOptions changes the TLS handshake of the requests sent through a proxy, so the handshake can be made
+// to look like the one of the official client
+type TLSOptions struct {
+	// Config replaces the default TLS configuration, like the cipher suites and curve preferences
+	Config *tls.Config
+	// Handshake performs the TLS handshake over the connection to the server, for example with a library
+	// that mimics the ClientHello of another client. It is only supported for socks5 proxies, as the
+	// handshake through http proxies is performed by the standard library
+	Handshake func(conn net.Conn, serverName string) (net.Conn, error)
+}
+
+// SetProxyTLS sets the TLS options of the requests sent through the registered proxy,
+// requests through proxies without TLS options use the standard TLS handshake
+func (c *RPC) SetProxyTLS(id int64, options TLSOptions) error {
+	c.proxies.mu.Lock()
+	defer c.proxies.mu.Unlock()
+
+	client, ok := c.proxies.clients[id]
+	if !ok {
+		return ErrUnknownProxy
+	}
+	transport := client.Transport.(*http.Transport).Clone()
+	transport.TLSClientConfig = options.Config
+
+	if options.Handshake != nil {
+		if transport.Proxy != nil {
+			return raise("A custom TLS handshake is only supported for socks5 proxies")
+		}
+		dial, handshake := transport.DialContext, options.Handshake
+		transport.DialTLSContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+			conn, err := dial(ctx, network, addr)
+			if err != nil {
+				return nil, err
+			}
+			host, _, err := net.SplitHostPort(addr)
+			if err != nil {
+				conn.Close()
+				return nil, err
+			}
+			tlsConn, err := handshake(conn, host)
+			if err != nil {
+				conn.Close()
+				return nil, err
+			}
+			return tlsConn, nil
+		}
+	}
+
+	c.proxies.clients[id] = &http.Client{
+		Jar:           client.Jar,
+		CheckRedirect: client.CheckRedirect,
+		Transport:     transport,
+	}
+	return nil
+}
@@ -0,0 +1,56 @@
+package api
+
+import (
+	"sync"
+	"time"
+)
+
+// defaultProxyCooldown is how long a proxy is avoided after it was found dead
+const defaultProxyCooldown = 5 * time.Minute
+
+// ProxyCooldown keeps track of dead proxies until their cooldown expires, it is safe for concurrent use
+type ProxyCooldown struct {
+	mu       sync.Mutex
+	cooldown time.Duration
+	dead     map[int64]time.Time
+}
+
+// NewProxyCooldown constructs a tracker keeping proxies marked as dead for the cooldown duration
+func NewProxyCooldown(cooldown time.Duration) *ProxyCooldown {
+	return &ProxyCooldown{
+		cooldown: cooldown,
+		dead:     make(map[int64]time.Time),
+	}
+}
+
+// MarkDead marks the proxy as dead until the cooldown expires
+func (p *ProxyCooldown) MarkDead(proxyId int64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.dead[proxyId] = time.Now().Add(p.cooldown)
+}
+
+// IsDead checks whether the proxy is still in its cooldown
+func (p *ProxyCooldown) IsDead(proxyId int64) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	until, ok := p.dead[proxyId]
+	if !ok {
+		return false
+	}
+	if time.Now().After(until) {
+		delete(p.dead, proxyId)
+		return false
+	}
+	return true
+}
+
+// MarkProxyDead keeps the proxy from being used by the RPC client of the session until its cooldown expires
+func (s *Session) MarkProxyDead(proxyId int64) {
+	s.rpc.deadProxies.MarkDead(proxyId)
+}
+
+// IsProxyDead checks whether the proxy has been found dead recently by the RPC client of the session
+func (s *Session) IsProxyDead(proxyId int64) bool {
+	return s.rpc.deadProxies.IsDead(proxyId)
+}
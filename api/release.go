@@ -0,0 +1,65 @@
+package api
+
+import (
+	"errors"
+
+	"github.com/golang/protobuf/proto"
+	"golang.org/x/net/context"
+
+	protos "github.com/pogodevorg/POGOProtos-go"
+)
+
+// ReleaseResult is the outcome of releasing a pokémon
+type ReleaseResult struct {
+	Response *protos.ReleasePokemonResponse
+	// CandyTotal is the candy held for the family of the pokémon after the release,
+	// it is only known when the inventory has been fetched by the session before
+	CandyTotal int32
+	CandyKnown bool
+}
+
+// trackCandy remembers the candy held for every family in a fetched inventory
+func (s *Session) trackCandy(inventory *protos.GetInventoryResponse) {
+	s.candy = NewInventoryView(inventory).Candy
+}
+
+// ReleasePokemon transfers the pokémon to the professor, family is the family of the pokémon and is used
+// to add the awarded candy to the candy of the last fetched inventory, so that the new total is known
+// without fetching the inventory again
+func (s *Session) ReleasePokemon(ctx context.Context, pokemonID uint64, family protos.PokemonFamilyId, proxyId int64) (*ReleaseResult, error) {
+	requestMessage, err := proto.Marshal(&protos.ReleasePokemonMessage{
+		PokemonId: pokemonID,
+	})
+	if err != nil {
+		return nil, ErrFormatting
+	}
+
+	requests := []*protos.Request{{RequestType: protos.RequestType_RELEASE_POKEMON, RequestMessage: requestMessage}}
+	response, err := s.Call(ctx, requests, proxyId)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(response.Returns) < 1 {
+		return nil, errors.New("Empty response")
+	}
+
+	release := &protos.ReleasePokemonResponse{}
+	err = unmarshalChecked(response.Returns[0], release)
+	if err != nil {
+		return nil, err
+	}
+	s.feed.Push(release)
+	s.debugProtoMessage("response return[0]", release)
+
+	result := &ReleaseResult{Response: release}
+	if s.candy != nil {
+		if release.Result == protos.ReleasePokemonResponse_SUCCESS {
+			s.candy[family] += release.CandyAwarded
+		}
+		result.CandyTotal = s.candy[family]
+		result.CandyKnown = true
+	}
+
+	return result, newErrCall(requests, response, GetErrorFromStatus(response.StatusCode))
+}
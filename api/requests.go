@@ -0,0 +1,51 @@
+package api
+
+import (
+	protos "github.com/pogodevorg/POGOProtos-go"
+)
+
+// DefaultCommonRequests is the set and order of the requests the official client sends along with
+// its calls, Init sends them on their own and Announce sends the map request after them
+var DefaultCommonRequests = []protos.RequestType{
+	protos.RequestType_GET_PLAYER,
+	protos.RequestType_GET_HATCHED_EGGS,
+	protos.RequestType_GET_INVENTORY,
+	protos.RequestType_CHECK_AWARDED_BADGES,
+	protos.RequestType_DOWNLOAD_SETTINGS,
+}
+
+// SetCommonRequests sets the set and order of the requests sent along with Init and Announce,
+// nil restores DefaultCommonRequests
+func (s *Session) SetCommonRequests(order []protos.RequestType) {
+	s.commonRequestOrder = order
+}
+
+// commonRequests builds the common requests in the configured order,
+// the inventory request carries inventoryMessage
+func (s *Session) commonRequests(inventoryMessage []byte) []*protos.Request {
+	order := s.commonRequestOrder
+	if order == nil {
+		order = DefaultCommonRequests
+	}
+
+	requests := make([]*protos.Request, 0, len(order))
+	for _, requestType := range order {
+		switch requestType {
+		case protos.RequestType_GET_PLAYER:
+			requests = append(requests, getPlayerRequest)
+		case protos.RequestType_GET_HATCHED_EGGS:
+			requests = append(requests, getHatchedEggsRequest)
+		case protos.RequestType_GET_INVENTORY:
+			requests = append(requests, &protos.Request{RequestType: protos.RequestType_GET_INVENTORY, RequestMessage: inventoryMessage})
+		case protos.RequestType_CHECK_AWARDED_BADGES:
+			requests = append(requests, checkAwardedBadgesRequest)
+		case protos.RequestType_DOWNLOAD_SETTINGS:
+			requests = append(requests, s.getDownloadSettingsRequest())
+		case protos.RequestType_DOWNLOAD_REMOTE_CONFIG_VERSION:
+			requests = append(requests, s.getRemoteConfigRequest())
+		default:
+			requests = append(requests, &protos.Request{RequestType: requestType})
+		}
+	}
+	return requests
+}
@@ -0,0 +1,50 @@
+package api
+
+import (
+	"errors"
+	"net"
+	"time"
+)
+
+// RetryPolicy makes Call send a failed call again when it failed on a network error or was throttled,
+// a throttled call waits longer as the remote service keeps throttling a session that retries right away
+type RetryPolicy struct {
+	// MaxRetries is the number of times a failed call is sent again
+	MaxRetries int
+	// Backoff is the pause before the first retry after a network error, it doubles with every retry
+	Backoff time.Duration
+	// ThrottledBackoff is the pause before the first retry of a throttled call, it doubles with every retry
+	ThrottledBackoff time.Duration
+}
+
+// DefaultRetryPolicy retries a call three times, a throttled call is held back ten times as long
+var DefaultRetryPolicy = RetryPolicy{
+	MaxRetries:       3,
+	Backoff:          time.Second,
+	ThrottledBackoff: 10 * time.Second,
+}
+
+// SetRetryPolicy makes Call retry failed calls according to the policy, nil turns retries off, which is the default.
+// Any other error, like an account ban or a dead proxy, is given right away
+func (s *Session) SetRetryPolicy(policy *RetryPolicy) {
+	s.retryPolicy = policy
+}
+
+// backoff returns the pause before the retry after the given number of retries of a call that failed with err,
+// it is false when the call is not sent again
+func (p *RetryPolicy) backoff(err error, retries int) (time.Duration, bool) {
+	if retries >= p.MaxRetries {
+		return 0, false
+	}
+	var base time.Duration
+	var netErr net.Error
+	switch {
+	case errors.Is(err, ErrThrottled):
+		base = p.ThrottledBackoff
+	case errors.As(err, &netErr):
+		base = p.Backoff
+	default:
+		return 0, false
+	}
+	return base << uint(retries), true
+}
@@ -0,0 +1,36 @@
+package api
+
+import (
+	"errors"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestRetryPolicyBackoff(t *testing.T) {
+	policy := &RetryPolicy{MaxRetries: 2, Backoff: time.Second, ThrottledBackoff: 10 * time.Second}
+	networkErr := raiseErr("There was an error requesting the API", &net.OpError{Op: "dial", Err: errors.New("connection refused")})
+	throttledErr := newErrCall(nil, nil, ErrThrottled)
+
+	tests := []struct {
+		err     error
+		retries int
+		backoff time.Duration
+		retry   bool
+	}{
+		{networkErr, 0, time.Second, true},
+		{networkErr, 1, 2 * time.Second, true},
+		{networkErr, 2, 0, false},
+		{throttledErr, 0, 10 * time.Second, true},
+		{throttledErr, 1, 20 * time.Second, true},
+		{ErrAccountBanned, 0, 0, false},
+		{ErrProxyDead, 0, 0, false},
+	}
+
+	for _, test := range tests {
+		backoff, retry := policy.backoff(test.err, test.retries)
+		if backoff != test.backoff || retry != test.retry {
+			t.Errorf("backoff(%v, %d) = %s, %t, expected %s, %t", test.err, test.retries, backoff, retry, test.backoff, test.retry)
+		}
+	}
+}
@@ -2,13 +2,15 @@ package api
 
 import (
 	"bytes"
-	"golang.org/x/net/context"
 	"fmt"
-	"io/ioutil"
+	"golang.org/x/net/context"
 	"net"
 	"net/http"
 	"net/http/cookiejar"
 	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/golang/protobuf/proto"
 	"golang.org/x/net/context/ctxhttp"
@@ -31,15 +33,51 @@ func raise(message string) error {
 	return fmt.Errorf("rpc/client: %s", message)
 }
 
+// raiseErr works like raise for a message about err, the error still matches err with errors.Is and errors.As
+func raiseErr(message string, err error) error {
+	return fmt.Errorf("rpc/client: %s: %w", message, err)
+}
+
+// The buffers used to encode requests and read responses are reused between requests,
+// the decoded messages don't hold on to them
+var requestBufferPool = sync.Pool{
+	New: func() interface{} { return proto.NewBuffer(nil) },
+}
+var responseBufferPool = sync.Pool{
+	New: func() interface{} { return new(bytes.Buffer) },
+}
+
+// RPCOptions contains the tunables of the RPC client
+type RPCOptions struct {
+	// MaxInFlightPerProxy limits the number of simultaneous requests sent through a single proxy, zero means no limit
+	MaxInFlightPerProxy int
+	// ProxyCooldown keeps track of the dead proxies, pass the same one to the RPC clients that share their proxies.
+	// Every RPC client keeps its own when it is nil
+	ProxyCooldown *ProxyCooldown
+	// LatencyAnomaly is called when a response through a proxy takes far longer or shorter than usual,
+	// the standard logger is warned when it is nil
+	LatencyAnomaly LatencyAnomalyFunc
+}
+
 // RPC is used to communicate with the Pokémon Go API
 type RPC struct {
-	http *http.Client
+	http        *http.Client
+	timeout     time.Duration
+	inFlight    *proxySemaphores
+	deadProxies *ProxyCooldown
+	dump        atomic.Value
+	proxies     *proxyRegistry
+	latency     *latencyTracker
 }
 
 // NewRPC constructs a Pokémon Go RPC API client
 func NewRPC() *RPC {
-	options := &cookiejar.Options{}
-	jar, _ := cookiejar.New(options)
+	return NewRPCWithOptions(RPCOptions{})
+}
+
+// NewRPCWithOptions constructs a Pokémon Go RPC API client with the given options
+func NewRPCWithOptions(options RPCOptions) *RPC {
+	jar, _ := cookiejar.New(&cookiejar.Options{})
 	httpClient := &http.Client{
 		Jar: jar,
 		CheckRedirect: func(req *http.Request, via []*http.Request) error {
@@ -47,8 +85,75 @@ func NewRPC() *RPC {
 		},
 	}
 
-	return &RPC{
-		http: httpClient,
+	deadProxies := options.ProxyCooldown
+	if deadProxies == nil {
+		deadProxies = NewProxyCooldown(defaultProxyCooldown)
+	}
+
+	c := &RPC{
+		http:        httpClient,
+		inFlight:    newProxySemaphores(options.MaxInFlightPerProxy),
+		deadProxies: deadProxies,
+		proxies:     newProxyRegistry(),
+		latency:     newLatencyTracker(),
+	}
+	if options.LatencyAnomaly != nil {
+		c.latency.setAnomalyFunc(options.LatencyAnomaly)
+	}
+	return c
+}
+
+// SetOptions replaces the options of the RPC client, requests already in flight are not affected.
+// Without a proxy cooldown or latency anomaly function in the options the client keeps the one it has
+func (c *RPC) SetOptions(options RPCOptions) {
+	c.inFlight.setLimit(options.MaxInFlightPerProxy)
+	if options.ProxyCooldown != nil {
+		c.deadProxies = options.ProxyCooldown
+	}
+	if options.LatencyAnomaly != nil {
+		c.latency.setAnomalyFunc(options.LatencyAnomaly)
+	}
+}
+
+type proxySemaphores struct {
+	mu    sync.Mutex
+	limit int
+	slots map[int64]chan struct{}
+}
+
+func newProxySemaphores(limit int) *proxySemaphores {
+	return &proxySemaphores{
+		limit: limit,
+		slots: make(map[int64]chan struct{}),
+	}
+}
+
+func (p *proxySemaphores) setLimit(limit int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.limit = limit
+	p.slots = make(map[int64]chan struct{})
+}
+
+// acquire blocks until a request slot for the proxy is available and returns a function releasing it
+func (p *proxySemaphores) acquire(ctx context.Context, proxyId int64) (func(), error) {
+	p.mu.Lock()
+	if p.limit <= 0 {
+		p.mu.Unlock()
+		return func() {}, nil
+	}
+	slot, ok := p.slots[proxyId]
+	if !ok {
+		slot = make(chan struct{}, p.limit)
+		p.slots[proxyId] = slot
+	}
+	p.mu.Unlock()
+
+	select {
+	case slot <- struct{}{}:
+		return func() { <-slot }, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
 	}
 }
 
@@ -56,16 +161,45 @@ func NewRPC() *RPC {
 func (c *RPC) Request(ctx context.Context, endpoint string, requestEnvelope *protos.RequestEnvelope, proxyId int64) (responseEnvelope *protos.ResponseEnvelope, err error) {
 	responseEnvelope = &protos.ResponseEnvelope{}
 
+	// A deadline on the context takes precedence over the default timeout
+	if _, ok := ctx.Deadline(); !ok && c.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.timeout)
+		defer cancel()
+	}
+
 	// Build reques
-	requestBytes, err := proto.Marshal(requestEnvelope)
+	requestBuffer := requestBufferPool.Get().(*proto.Buffer)
+	requestBuffer.Reset()
+	err = requestBuffer.Marshal(requestEnvelope)
+	// The transport may still read the body after the request returned, so it gets its own copy of the encoded envelope
+	requestBody := append([]byte(nil), requestBuffer.Bytes()...)
+	requestBufferPool.Put(requestBuffer)
 	if err != nil {
 		return responseEnvelope, raise("Could not encode request body")
 	}
-	requestReader := bytes.NewReader(requestBytes)
+	requestReader := bytes.NewReader(requestBody)
+
+	if proxyId != -1 && c.deadProxies.IsDead(proxyId) {
+		return responseEnvelope, ErrProxyDead
+	}
+
+	// Registered proxies are used directly, the others go through the proxy host
+	httpClient := c.http
+	gateway := false
+	if proxyId != -1 {
+		if proxyClient, ok := c.proxyClient(proxyId); ok {
+			httpClient = proxyClient
+		} else if ProxyHost != "" {
+			gateway = true
+		} else {
+			return responseEnvelope, ErrUnknownProxy
+		}
+	}
 
 	// Create request
 	var request *http.Request
-	if proxyId != -1 {
+	if gateway {
 		request, err = http.NewRequest("POST", ProxyHost, requestReader)
 		request.Header.Add("Proxy-Id", strconv.FormatInt(proxyId, 10))
 		request.Header.Add("Final-Host", endpoint)
@@ -79,14 +213,27 @@ func (c *RPC) Request(ctx context.Context, endpoint string, requestEnvelope *pro
 	}
 	request.Header.Add("User-Agent", rpcUserAgent)
 
+	release, err := c.inFlight.acquire(ctx, proxyId)
+	if err != nil {
+		return responseEnvelope, raise(fmt.Sprintf("Could not acquire a request slot for proxy %d: %s", proxyId, err))
+	}
+	defer release()
+
+	// Only requests that are sent are dumped, not the ones for a dead or unknown proxy
+	dump := c.envelopeDump()
+	dump.write(EnvelopeDumpRequest, requestBody)
+
 	// Perform call to API
-	response, err := ctxhttp.Do(ctx, c.http, request)
+	start := time.Now()
+	response, err := ctxhttp.Do(ctx, httpClient, request)
 	if err != nil {
-		return responseEnvelope, raise(fmt.Sprintf("There was an error requesting the API: %s", err))
+		return responseEnvelope, raiseErr("There was an error requesting the API", err)
 	}
 	defer response.Body.Close()
 
-	if response.StatusCode == 400 {
+	// The proxy host signals a dead proxy with a bad request, a bad request from the remote service isn't one
+	if gateway && response.StatusCode == 400 {
+		c.deadProxies.MarkDead(proxyId)
 		return responseEnvelope, ErrProxyDead
 	}
 
@@ -95,16 +242,22 @@ func (c *RPC) Request(ctx context.Context, endpoint string, requestEnvelope *pro
 	}
 
 	// Read the response
-	responseBytes, err := ioutil.ReadAll(response.Body)
+	responseBuffer := responseBufferPool.Get().(*bytes.Buffer)
+	responseBuffer.Reset()
+	defer responseBufferPool.Put(responseBuffer)
+	_, err = responseBuffer.ReadFrom(response.Body)
 	if err != nil {
 		return responseEnvelope, raise("Could not read response body")
 	}
+	// The response time includes reading the body, a proxy stalling halfway through a response is slow as well
+	c.latency.observe(proxyId, time.Since(start))
+	responseBytes := responseBuffer.Bytes()
 
-	if proxyId != -1 {
+	if gateway {
 		var proxyResponse = &ProxyResponse{}
 		err = json.Unmarshal(responseBytes, proxyResponse)
 		if err != nil {
-			return responseEnvelope, raise("Could not decode response body")
+			return responseEnvelope, raiseErr("Could not decode response body", err)
 		}
 		if proxyResponse.Status != 200 {
 			return responseEnvelope, raise(fmt.Sprintf("Status code was %d, expected 200", proxyResponse.Status))
@@ -115,13 +268,18 @@ func (c *RPC) Request(ctx context.Context, endpoint string, requestEnvelope *pro
 			return responseEnvelope, err
 		}
 
+		dump.write(EnvelopeDumpResponse, decoded)
 		err = proto.Unmarshal(decoded, responseEnvelope)
 		if err != nil {
 			log.Println(err)
 			return responseEnvelope, err
 		}
 	} else {
-		proto.Unmarshal(responseBytes, responseEnvelope)
+		dump.write(EnvelopeDumpResponse, responseBytes)
+		err = proto.Unmarshal(responseBytes, responseEnvelope)
+		if err != nil {
+			return responseEnvelope, raiseErr("Could not decode response body", err)
+		}
 	}
 	return responseEnvelope, nil
 }
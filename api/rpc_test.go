@@ -0,0 +1,37 @@
+package api
+
+import (
+	"net/http"
+	"testing"
+
+	"golang.org/x/net/context"
+
+	protos "github.com/pogodevorg/POGOProtos-go"
+)
+
+func BenchmarkRPCRequest(b *testing.B) {
+	rpc := NewRPC()
+	rpc.http = &http.Client{Transport: &fakeTransport{respond: okResponse}}
+
+	requestEnvelope := &protos.RequestEnvelope{
+		RequestId:  initialRequestID,
+		StatusCode: 2,
+		Requests: []*protos.Request{
+			{RequestType: protos.RequestType_GET_PLAYER},
+			{RequestType: protos.RequestType_GET_HATCHED_EGGS},
+			{RequestType: protos.RequestType_GET_INVENTORY},
+			{RequestType: protos.RequestType_CHECK_AWARDED_BADGES},
+			{RequestType: protos.RequestType_DOWNLOAD_SETTINGS},
+		},
+	}
+	ctx := context.Background()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_, err := rpc.Request(ctx, defaultURL, requestEnvelope, -1)
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -1,11 +1,15 @@
 package api
 
 import (
-	"golang.org/x/net/context"
-	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
 	"fmt"
+	"golang.org/x/net/context"
+	"io"
 	"log"
+	mathrand "math/rand"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/golang/protobuf/jsonpb"
@@ -19,24 +23,117 @@ import (
 )
 
 const defaultURL = "https://pgorelease.nianticlabs.com/plfe/rpc"
-const downloadSettingsHash = "05daf51635c82611d1aac95c0b051d3ec088a930"
+const defaultDownloadSettingsHash = "05daf51635c82611d1aac95c0b051d3ec088a930"
+
+// Requests without a payload are shared between calls
+var getPlayerRequest = &protos.Request{RequestType: protos.RequestType_GET_PLAYER}
+var getHatchedEggsRequest = &protos.Request{RequestType: protos.RequestType_GET_HATCHED_EGGS}
+var checkAwardedBadgesRequest = &protos.Request{RequestType: protos.RequestType_CHECK_AWARDED_BADGES}
+
+// DefaultAPIVersion is the version of the official client the session identifies as by default
+const DefaultAPIVersion uint64 = 4500
+
+const recycleInterval = 500 * time.Millisecond
+
+// maxPositionDrift is the distance in meters a player position sent in a request may be from the location of the session
+const maxPositionDrift = 10.0
+
+// authInfoUnknown2 is sent with the auth token of the calls made before there is an auth ticket,
+// it is the same for all API versions
+const authInfoUnknown2 int32 = 59
+
+// maxRedirects is the number of times a call follows the remote service to a new endpoint
+const maxRedirects = 3
 
 // Session is used to communicate with the Pokémon Go API
 type Session struct {
 	feed     Feed
 	signer   *newcrypto.PogoSignature
+	hasher   Hasher
 	location *Location
-	rpc      *RPC
-	RPCID    uint64
-	url      string
-	debug    bool
-	debugger *jsonpb.Marshaler
-
-	hasTicket bool
-	ticket    *protos.AuthTicket
-	started   time.Time
-	provider  auth.Provider
-	hash      []byte
+	accuracy *accuracyModel
+	movement movement
+	activity *protos.Signature_ActivityStatus
+
+	signatureBuilder func(signature *protos.Signature)
+
+	challengeActive bool
+	challengeURL    string
+
+	fortCooldowns map[string]time.Time
+	bag           bagTracker
+	candy         map[protos.PokemonFamilyId]int32
+
+	lastFix       time.Time
+	locationFixes []*protos.Signature_LocationFix
+	rpc           *RPC
+	RPCID         uint64
+	url           string
+	debug         bool
+	debugger      *jsonpb.Marshaler
+	label         string
+
+	hasTicket   bool
+	ticket      *protos.AuthTicket
+	ticketBytes []byte
+	started     time.Time
+	provider    auth.Provider
+	hash        []byte
+	hashSet     bool
+	random      io.Reader
+
+	apiVersion uint64
+
+	playerCache    playerCache
+	settings       *protos.GlobalSettings
+	lastMapRequest time.Time
+
+	downloadSettingsHash    string
+	downloadSettingsRequest *protos.Request
+
+	loginTimeout time.Duration
+	initTimeout  time.Duration
+
+	stripEmptyCells        bool
+	announceCheckChallenge bool
+	skipTeamCheck          bool
+	cellShuffle            *mathrand.Rand
+	warmUp                 warmUpDelays
+	captchaSolver          CaptchaSolver
+	commonRequestOrder     []protos.RequestType
+	callCounter            callCounter
+	skipSignature          bool
+	moveGate               moveGate
+	jitterPct              float64
+	incrementalMap         bool
+	cellTimestamps         map[uint64]int64
+	middleware             []CallMiddleware
+	softBan                softBanDetector
+	maxCells               int
+	altitudeProvider       AltitudeProvider
+	altitudes              map[uint64]float64
+	mapConcurrency         int
+	requestIDFunc          func() uint64
+	autoRelogin            bool
+	retryPolicy            *RetryPolicy
+	logins                 int
+	gameMaster             *GameMaster
+	itemTemplatesTimestamp uint64
+	remoteConfigSeen       bool
+	throttle               throttle
+	callInterval           time.Duration
+	lastCallSlot           time.Time
+	deviceInfo             *protos.Signature_DeviceInfo
+	logger                 *log.Logger
+	lastMapTime            time.Time
+	timestampedMapFeed     bool
+	cellCache              map[uint64]*cachedCell
+	changedCellsFeed       bool
+	authExpiry             time.Time
+	trackItems             bool
+	itemsGained            map[protos.ItemId]int32
+	callMu                 sync.Mutex
+	recoveryMu             sync.Mutex
 }
 
 func generateRequests() []*protos.Request {
@@ -47,20 +144,81 @@ func getTimestamp(t time.Time) uint64 {
 	return uint64(t.UnixNano() / int64(time.Millisecond))
 }
 
-// NewSession constructs a Pokémon Go RPC API client
+// NewSession constructs a Pokémon Go RPC API client, signer may be nil when a hasher is set with SetHasher,
+// otherwise the authenticated calls give ErrNoSigner
 func NewSession(signer *newcrypto.PogoSignature, provider auth.Provider, location *Location, feed Feed, debug bool) *Session {
-	return &Session{
-		location:  location,
-		rpc:       NewRPC(),
-		signer:    signer,
-		provider:  provider,
-		debug:     debug,
-		debugger:  &jsonpb.Marshaler{Indent: "\t"},
-		feed:      feed,
-		started:   time.Now(),
-		hasTicket: false,
-		hash:      make([]byte, 32),
+	return NewSessionWithOptions(
+		WithSigner(signer),
+		WithProvider(provider),
+		WithLocation(location),
+		WithFeed(feed),
+		WithDebug(debug),
+	)
+}
+
+// CloneForProvider constructs a session for another account that shares the signer, hasher, feed and RPC client
+// of the session as well as its settings. The account state, like the auth ticket, session hash, request ids, caches
+// and cooldowns, starts out fresh and the clone identifies as the same device model with a device id of its own.
+// Note that the RPC client is shared, so SetTimeout and SetRPCOptions on either session apply to both
+func (s *Session) CloneForProvider(provider auth.Provider, location *Location) *Session {
+	clone := NewSession(s.signer, provider, location, s.feed, s.debug)
+	clone.rpc = s.rpc
+	clone.logger = s.logger
+	clone.callInterval = s.callInterval
+	clone.hasher = s.hasher
+	clone.debugger = s.debugger
+	clone.random = s.random
+	clone.apiVersion = s.apiVersion
+	clone.accuracy = s.accuracy
+	clone.signatureBuilder = s.signatureBuilder
+	clone.loginTimeout = s.loginTimeout
+	clone.initTimeout = s.initTimeout
+	clone.playerCache.ttl = s.playerCache.ttl
+	clone.bag.check = s.bag.check
+	clone.bag.maxSize = s.bag.maxSize
+	clone.stripEmptyCells = s.stripEmptyCells
+	clone.changedCellsFeed = s.changedCellsFeed
+	clone.announceCheckChallenge = s.announceCheckChallenge
+	clone.skipTeamCheck = s.skipTeamCheck
+	clone.warmUp = s.warmUp
+	clone.jitterPct = s.jitterPct
+	clone.captchaSolver = s.captchaSolver
+	clone.commonRequestOrder = append([]protos.RequestType(nil), s.commonRequestOrder...)
+	clone.skipSignature = s.skipSignature
+	clone.softBan.threshold = s.softBan.threshold
+	clone.maxCells = s.maxCells
+	clone.autoRelogin = s.autoRelogin
+	clone.retryPolicy = s.retryPolicy
+	clone.mapConcurrency = s.mapConcurrency
+	clone.altitudeProvider = s.altitudeProvider
+	clone.middleware = append([]CallMiddleware(nil), s.middleware...)
+	clone.moveGate = moveGate{minDistance: s.moveGate.minDistance, sameSpotLimit: s.moveGate.sameSpotLimit}
+	clone.incrementalMap = s.incrementalMap
+	clone.timestampedMapFeed = s.timestampedMapFeed
+	clone.trackItems = s.trackItems
+	clone.downloadSettingsHash = s.downloadSettingsHash
+	clone.activity = s.activity
+	clone.label = s.label
+	if s.cellShuffle != nil {
+		// A rand source isn't safe to share between sessions, the clone gets its own seeded from this one
+		clone.cellShuffle = mathrand.New(mathrand.NewSource(s.cellShuffle.Int63()))
+	}
+	if s.deviceInfo != nil {
+		deviceInfo := proto.Clone(s.deviceInfo).(*protos.Signature_DeviceInfo)
+		deviceInfo.DeviceId = clone.newDeviceID()
+		clone.deviceInfo = deviceInfo
+	}
+	return clone
+}
+
+// newDeviceID generates a device id in the form of the one of an iOS device, 32 hexadecimal characters
+func (s *Session) newDeviceID() string {
+	id := make([]byte, 16)
+	_, err := io.ReadFull(s.random, id)
+	if err != nil {
+		mathrand.Read(id)
 	}
+	return hex.EncodeToString(id)
 }
 
 // IsExpired checks the expiration timestamp of the sessions AuthTicket
@@ -73,14 +231,130 @@ func (s *Session) IsExpired() bool {
 	return s.ticket.ExpireTimestampMs < getTimestamp(time.Now())
 }
 
-// SetTimeout sets the client timeout for the RPC API
+// SetRandSource sets the source of randomness of the session, it is used for the session hash, the sampled accuracy
+// and the pauses of the helpers. A deterministic reader makes the session reproducible in tests
+func (s *Session) SetRandSource(random io.Reader) {
+	s.random = random
+}
+
+// randFloat64 returns a random number in [0, 1) read from the source of randomness of the session,
+// when the source fails, like a deterministic reader that ran out, it falls back to math/rand
+func (s *Session) randFloat64() float64 {
+	var b [8]byte
+	_, err := io.ReadFull(s.random, b[:])
+	if err != nil {
+		return mathrand.Float64()
+	}
+	return float64(binary.BigEndian.Uint64(b[:])>>11) / (1 << 53)
+}
+
+// SetAPIVersion sets the version of the official client the session identifies as,
+// it is passed to the hasher and used in the version dependent request fields. The local signer only
+// signs for DefaultAPIVersion, calls with another version give ErrUnsupportedAPIVersion unless a hasher
+// for that version is set with SetHasher
+func (s *Session) SetAPIVersion(version uint64) {
+	s.apiVersion = version
+}
+
+// SetDownloadSettingsHash sets the hash of the settings the client claims to have
+func (s *Session) SetDownloadSettingsHash(hash string) {
+	s.downloadSettingsHash = hash
+	s.downloadSettingsRequest = nil
+}
+
+// getRemoteConfigRequest builds the remote config version request for the API version of the session
+func (s *Session) getRemoteConfigRequest() *protos.Request {
+	remoteConfigMessage, _ := proto.Marshal(&protos.DownloadRemoteConfigVersionMessage{
+		Platform:           protos.Platform_IOS,
+		DeviceManufacturer: "Apple",
+		DeviceModel:        "iPhone",
+		Locale:             "en-US",
+		AppVersion:         uint32(s.apiVersion),
+	})
+	return &protos.Request{
+		RequestType:    protos.RequestType_DOWNLOAD_REMOTE_CONFIG_VERSION,
+		RequestMessage: remoteConfigMessage,
+	}
+}
+
+// getDownloadSettingsRequest returns the download settings request, it is only marshalled again when the hash changes
+func (s *Session) getDownloadSettingsRequest() *protos.Request {
+	if s.downloadSettingsRequest == nil {
+		settingsMessage, _ := proto.Marshal(&protos.DownloadSettingsMessage{
+			Hash: s.downloadSettingsHash,
+		})
+		s.downloadSettingsRequest = &protos.Request{
+			RequestType:    protos.RequestType_DOWNLOAD_SETTINGS,
+			RequestMessage: settingsMessage,
+		}
+	}
+	return s.downloadSettingsRequest
+}
+
+// SetHasher makes the session compute the signature hashes with the given hasher,
+// when no hasher is set the hashes are computed locally
+func (s *Session) SetHasher(hasher Hasher) {
+	s.hasher = hasher
+}
+
+func (s *Session) getHasher() Hasher {
+	if s.hasher != nil {
+		return s.hasher
+	}
+	return &signerHasher{s.signer}
+}
+
+// SetSignatureBuilder sets a function that is called with the signature right before it is encrypted,
+// it can populate fields the package doesn't know about yet. Pass nil to send the signature as built
+func (s *Session) SetSignatureBuilder(builder func(signature *protos.Signature)) {
+	s.signatureBuilder = builder
+}
+
+// SetTimeout sets the default timeout for the RPC API, it applies to calls
+// made with a context without a deadline. Pass a context with a deadline to a call
+// to give that call a different timeout, like a longer one for large downloads
 func (s *Session) SetTimeout(d time.Duration) {
-	s.rpc.http.Timeout = d
+	s.rpc.timeout = d
+}
+
+// SetRPCOptions configures the underlying RPC client, like the per proxy concurrency limit
+func (s *Session) SetRPCOptions(options RPCOptions) {
+	s.rpc.SetOptions(options)
+}
+
+// SetStripEmptyCells makes Announce remove map cells without any map objects before pushing them to the feed,
+// use WithStripEmptyCells to do so for a single call of any of the map helpers
+func (s *Session) SetStripEmptyCells(strip bool) {
+	s.stripEmptyCells = strip
+}
+
+// SetSendSignature sets whether calls carry the encrypted request signature, they do by default.
+// The remote service refuses unsigned requests, leaving it out only helps to tell signing issues from network issues
+func (s *Session) SetSendSignature(send bool) {
+	s.skipSignature = !send
+}
+
+// SetCheckChallengeInAnnounce sets whether Announce includes a challenge check in its requests, it does by default
+func (s *Session) SetCheckChallengeInAnnounce(check bool) {
+	s.announceCheckChallenge = check
 }
 
 func (s *Session) setTicket(ticket *protos.AuthTicket) {
 	s.hasTicket = true
 	s.ticket = ticket
+	s.ticketBytes = nil
+}
+
+// getTicketBytes returns the marshalled auth ticket, it is only marshalled again when the ticket changes
+func (s *Session) getTicketBytes() ([]byte, error) {
+	if s.ticketBytes == nil {
+		ticket, err := proto.Marshal(s.ticket)
+		if err != nil {
+			return nil, err
+		}
+		s.ticketBytes = ticket
+	}
+	return s.ticketBytes, nil
 }
 
 func (s *Session) setURL(urlToken string) {
@@ -97,26 +371,205 @@ func (s *Session) getURL() string {
 	return url
 }
 
+// SetAccountLabel sets an identifier for the account that is prefixed to every log line of the session
+// and given with its Stats, so the output of many sessions can be told apart
+func (s *Session) SetAccountLabel(label string) {
+	s.label = label
+}
+
+// logPrintln writes to the logger of the session, or to the standard logger when it has none,
+// prefixed with the account label when it has one
+func (s *Session) logPrintln(message string) {
+	if s.label != "" {
+		message = fmt.Sprintf("[%s] %s", s.label, message)
+	}
+	if s.logger != nil {
+		s.logger.Println(message)
+	} else {
+		log.Println(message)
+	}
+}
+
 func (s *Session) debugProtoMessage(label string, pb proto.Message) {
 	if s.debug {
 		str, _ := s.debugger.MarshalToString(pb)
-		log.Println(fmt.Sprintf("%s: %s", label, str))
+		s.logPrintln(fmt.Sprintf("%s: %s", label, str))
 	}
 }
 
 // Call queries the Pokémon Go API through RPC protobuf
 func (s *Session) Call(ctx context.Context, requests []*protos.Request, proxyId int64) (*protos.ResponseEnvelope, error) {
+	// Retries after a solved challenge or a re-login are part of the same call
+	s.callCounter.count(requests)
+	call := s.chain(s.recoveringCall)
+	response, err := call(ctx, requests, proxyId)
+	for attempt := 0; err != nil && s.retryPolicy != nil; attempt++ {
+		backoff, ok := s.retryPolicy.backoff(err, attempt)
+		if !ok {
+			break
+		}
+		if waitUntil(ctx, time.Now().Add(backoff)) != nil {
+			break
+		}
+		response, err = call(ctx, requests, proxyId)
+	}
+	return response, err
+}
+
+// recoveryKey marks the context of the calls made while recovering from a challenge or an invalid auth token,
+// those calls are not recovered from again
+type recoveryKey struct{}
+
+func withRecovery(ctx context.Context) context.Context {
+	return context.WithValue(ctx, recoveryKey{}, true)
+}
+
+// platformRequestsKey carries the platform requests of a call through Call and its middleware
+type platformRequestsKey struct{}
+
+func withPlatformRequests(ctx context.Context, platformRequests []*protos.RequestEnvelope_PlatformRequest) context.Context {
+	return context.WithValue(ctx, platformRequestsKey{}, platformRequests)
+}
+
+func platformRequestsFrom(ctx context.Context) []*protos.RequestEnvelope_PlatformRequest {
+	platformRequests, _ := ctx.Value(platformRequestsKey{}).([]*protos.RequestEnvelope_PlatformRequest)
+	return platformRequests
+}
+
+// recoveringCall performs the call, solves a detected challenge when there is a captcha solver
+// and logs in again on an invalid auth token when automatic re-login is enabled
+func (s *Session) recoveringCall(ctx context.Context, requests []*protos.Request, proxyId int64) (*protos.ResponseEnvelope, error) {
+	platformRequests := platformRequestsFrom(ctx)
+	if ctx.Value(recoveryKey{}) != nil {
+		return s.call(ctx, requests, platformRequests, proxyId)
+	}
+
+	// The calls made to recover do not carry the platform requests of this call
+	recoveryCtx := withPlatformRequests(ctx, nil)
+	logins := s.loginCount()
+	response, err := s.call(ctx, requests, platformRequests, proxyId)
+	if err == nil && s.captchaSolver != nil && s.HasChallenge() {
+		err = s.solveChallenge(recoveryCtx, proxyId)
+		if err != nil {
+			return response, err
+		}
+		return s.call(ctx, requests, platformRequests, proxyId)
+	}
+	if err == nil && response.StatusCode == protos.ResponseEnvelope_INVALID_AUTH_TOKEN && s.autoRelogin {
+		err = s.relogin(recoveryCtx, proxyId, logins)
+		if err != nil {
+			return response, err
+		}
+		return s.call(ctx, requests, platformRequests, proxyId)
+	}
+	return response, err
+}
+
+func (s *Session) call(ctx context.Context, requests []*protos.Request, platformRequests []*protos.RequestEnvelope_PlatformRequest, proxyId int64) (*protos.ResponseEnvelope, error) {
+	// After a throttled response the calls are held back, with a longer pause for every throttled response in a row
+	s.callMu.Lock()
+	sendAt := s.throttle.until
+	if s.callInterval > 0 {
+		// Every call reserves its slot, so calls made at the same time are spread out as well
+		slot := s.lastCallSlot.Add(s.callInterval)
+		if slot.Before(time.Now()) {
+			slot = time.Now()
+		}
+		s.lastCallSlot = slot
+		if slot.After(sendAt) {
+			sendAt = slot
+		}
+	}
+	s.callMu.Unlock()
+	err := waitUntil(ctx, sendAt)
+	if err != nil {
+		return nil, err
+	}
+
+	// The session state is only locked while the envelope is built and the response is processed,
+	// so calls running at the same time are signed and wait for each other's responses concurrently
+	s.callMu.Lock()
+	requestEnvelope, pending, err := s.newRequestEnvelope(requests)
+	url := s.getURL()
+	s.callMu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	err = s.signRequestEnvelope(ctx, requestEnvelope, pending, platformRequests)
+	if err != nil {
+		return nil, err
+	}
+
+	responseEnvelope, err := s.rpc.Request(ctx, url, requestEnvelope, proxyId)
+	s.debugProtoMessage("response envelope", responseEnvelope)
+
+	// Follow the remote service to the new endpoint, but don't keep following it forever
+	for redirects := 0; err == nil && responseEnvelope.StatusCode == protos.ResponseEnvelope_REDIRECT && responseEnvelope.ApiUrl != ""; redirects++ {
+		if redirects >= maxRedirects {
+			return responseEnvelope, newErrCall(requests, responseEnvelope, ErrTooManyRedirects)
+		}
+		s.callMu.Lock()
+		s.setURL(responseEnvelope.ApiUrl)
+		url = s.getURL()
+		s.callMu.Unlock()
+
+		responseEnvelope, err = s.rpc.Request(ctx, url, requestEnvelope, proxyId)
+		s.debugProtoMessage("response envelope", responseEnvelope)
+	}
+
+	s.callMu.Lock()
+	defer s.callMu.Unlock()
+
+	if err == nil {
+		s.detectChallenge(requests, responseEnvelope)
+		s.throttle.track(responseEnvelope.StatusCode)
+	}
+
+	if err == nil && responseEnvelope.StatusCode == protos.ResponseEnvelope_BAD_REQUEST {
+		return responseEnvelope, newErrCall(requests, responseEnvelope, ErrAccountBanned)
+	}
+
+	if err == nil && responseEnvelope.StatusCode == protos.ResponseEnvelope_INVALID_PLATFORM_REQUEST {
+		return responseEnvelope, newErrCall(requests, responseEnvelope, ErrThrottled)
+	}
+
+	return responseEnvelope, newErrCall(requests, responseEnvelope, err)
+}
+
+// pendingSignature is the session state a signature is built from, it is taken under the call lock
+// so the request can be hashed and signed without holding it
+type pendingSignature struct {
+	hasher        Hasher
+	apiVersion    uint64
+	timestamp     uint64
+	sinceStart    uint64
+	location      Location
+	ticket        []byte
+	sessionHash   []byte
+	activity      *protos.Signature_ActivityStatus
+	deviceInfo    *protos.Signature_DeviceInfo
+	locationFixes []*protos.Signature_LocationFix
+	builder       func(signature *protos.Signature)
+}
+
+// newRequestEnvelope builds the request envelope of a call, it is called with the call lock held.
+// When the session has an auth ticket the state for the signature is returned along with it
+func (s *Session) newRequestEnvelope(requests []*protos.Request) (*protos.RequestEnvelope, *pendingSignature, error) {
+	// Without a hasher for it the calls would identify as a version they can't be signed for
+	if s.hasher == nil && s.apiVersion != DefaultAPIVersion {
+		return nil, nil, ErrUnsupportedAPIVersion
+	}
 
 	requestEnvelope := &protos.RequestEnvelope{
-		RequestId:  uint64(8145806132888207460),
+		RequestId:  s.nextRequestID(),
 		StatusCode: int32(2),
 
-		MsSinceLastLocationfix: int64(989),
+		MsSinceLastLocationfix: s.getMsSinceLastLocationFix(time.Now()),
 
 		Longitude: s.location.Lon,
 		Latitude:  s.location.Lat,
 
-		Accuracy: s.location.Accuracy,
+		Accuracy: s.getAccuracy(),
 
 		Requests: requests,
 	}
@@ -128,58 +581,73 @@ func (s *Session) Call(ctx context.Context, requests []*protos.Request, proxyId
 			Provider: s.provider.GetProviderString(),
 			Token: &protos.RequestEnvelope_AuthInfo_JWT{
 				Contents: s.provider.GetAccessToken(),
-				Unknown2: int32(59),
+				Unknown2: authInfoUnknown2,
 			},
 		}
 	}
 
-	if s.hasTicket {
-		t := getTimestamp(time.Now())
+	if !s.hasTicket || s.skipSignature {
+		return requestEnvelope, nil, nil
+	}
 
-		requestHash := make([]uint64, len(requests))
-		ticket, err := proto.Marshal(s.ticket)
-		if err != nil {
-			return nil, err
-		}
+	ticket, err := s.getTicketBytes()
+	if err != nil {
+		return nil, nil, err
+	}
+	t := getTimestamp(time.Now())
+	return requestEnvelope, &pendingSignature{
+		hasher:        s.getHasher(),
+		apiVersion:    s.apiVersion,
+		timestamp:     t,
+		sinceStart:    t - getTimestamp(s.started),
+		location:      *s.location,
+		ticket:        ticket,
+		sessionHash:   append([]byte(nil), s.hash...),
+		activity:      s.getActivityStatus(),
+		deviceInfo:    s.deviceInfo,
+		locationFixes: s.takeLocationFixes(),
+		builder:       s.signatureBuilder,
+	}, nil
+}
 
-		for idx, request := range requests {
+// signRequestEnvelope adds the signature to the request envelope, followed by the platform requests of the call.
+// It runs without the call lock, as hashing can be a round trip to a hash server and the signature builder may use the session
+func (s *Session) signRequestEnvelope(ctx context.Context, requestEnvelope *protos.RequestEnvelope, pending *pendingSignature, platformRequests []*protos.RequestEnvelope_PlatformRequest) error {
+	if pending != nil {
+		requestBytes := make([][]byte, len(requestEnvelope.Requests))
+		for idx, request := range requestEnvelope.Requests {
 			req, err := proto.Marshal(request)
 			if err != nil {
-				return nil, err
+				return err
 			}
-			hash := s.signer.HashRequest(ticket, req)
-			requestHash[idx] = hash
+			requestBytes[idx] = req
 		}
 
-		locationHash1 := s.signer.HashLocation1(ticket, s.location.Lat, s.location.Lon, s.location.Alt)
-		locationHash2 := s.signer.HashLocation2(s.location.Lat, s.location.Lon, s.location.Alt)
+		hashes, err := pending.hasher.Hash(ctx, pending.apiVersion, pending.timestamp, &pending.location, pending.ticket, pending.sessionHash, requestBytes)
+		if err != nil {
+			return err
+		}
 
 		signature := &protos.Signature{
-			RequestHash:   requestHash,
-			LocationHash1: locationHash1,
-			LocationHash2: locationHash2,
-			ActivityStatus: &protos.Signature_ActivityStatus{
-				Stationary: true,
-			},
-			DeviceInfo: &protos.Signature_DeviceInfo{
-				DeviceId:             "<device_id>",
-				DeviceBrand:          "Apple",
-				DeviceModel:          "iPhone",
-				DeviceModelBoot:      "Iphone7,2",
-				HardwareManufacturer: "Apple",
-				HardwareModel:        "N66AP",
-				FirmwareBrand:        "iPhone OS",
-				FirmwareType:         "9.3.3",
-			},
-			SessionHash:         s.hash,
-			Timestamp:           t,
-			TimestampSinceStart: (t - getTimestamp(s.started)),
-			Unknown25:           s.signer.Hash25(),
+			RequestHash:         hashes.RequestHash,
+			LocationHash1:       hashes.LocationHash1,
+			LocationHash2:       hashes.LocationHash2,
+			ActivityStatus:      pending.activity,
+			DeviceInfo:          pending.deviceInfo,
+			LocationFix:         pending.locationFixes,
+			SessionHash:         pending.sessionHash,
+			Timestamp:           pending.timestamp,
+			TimestampSinceStart: pending.sinceStart,
+			Unknown25:           hashes.Unknown25,
+		}
+
+		if pending.builder != nil {
+			pending.builder(signature)
 		}
 
 		signatureProto, err := proto.Marshal(signature)
 		if err != nil {
-			return nil, ErrFormatting
+			return ErrFormatting
 		}
 
 		encryptedSignature := newcrypto.Encrypt(signatureProto, uint32(signature.TimestampSinceStart))
@@ -188,59 +656,225 @@ func (s *Session) Call(ctx context.Context, requests []*protos.Request, proxyId
 			EncryptedSignature: encryptedSignature,
 		})
 		if err != nil {
-			return nil, ErrFormatting
+			return ErrFormatting
 		}
 
-		requestEnvelope.PlatformRequests = []*protos.RequestEnvelope_PlatformRequest{
-			{
-				Type:           protos.PlatformRequestType_SEND_ENCRYPTED_SIGNATURE,
-				RequestMessage: requestMessage,
-			},
-		}
+		requestEnvelope.PlatformRequests = append(requestEnvelope.PlatformRequests, &protos.RequestEnvelope_PlatformRequest{
+			Type:           protos.PlatformRequestType_SEND_ENCRYPTED_SIGNATURE,
+			RequestMessage: requestMessage,
+		})
 
 		s.debugProtoMessage("request signature", signature)
 	}
 
+	requestEnvelope.PlatformRequests = append(requestEnvelope.PlatformRequests, platformRequests...)
+
 	s.debugProtoMessage("request envelope", requestEnvelope)
 
-	responseEnvelope, err := s.rpc.Request(ctx, s.getURL(), requestEnvelope, proxyId)
+	return nil
+}
 
-	s.debugProtoMessage("response envelope", responseEnvelope)
+// hasRequest checks whether there is a request of the given type among the requests
+func hasRequest(requests []*protos.Request, requestType protos.RequestType) bool {
+	for _, request := range requests {
+		if request.RequestType == requestType {
+			return true
+		}
+	}
+	return false
+}
+
+// getReturn looks up the return of the first request of the given type, the returns are in the same order as the requests
+func getReturn(requests []*protos.Request, response *protos.ResponseEnvelope, requestType protos.RequestType) ([]byte, bool) {
+	for idx, request := range requests {
+		if request.RequestType == requestType {
+			if idx >= len(response.Returns) {
+				return nil, false
+			}
+			return response.Returns[idx], true
+		}
+	}
+	return nil, false
+}
 
-	return responseEnvelope, err
+// UnmarshalReturn decodes the return of the first request of the given type in the request envelope
+// of a Call in to out, it is safe to use on short or empty responses
+func UnmarshalReturn(response *protos.ResponseEnvelope, request *protos.RequestEnvelope, requestType protos.RequestType, out proto.Message) error {
+	if response == nil || request == nil {
+		return ErrNoReturn
+	}
+	buf, ok := getReturn(request.Requests, response, requestType)
+	if !ok {
+		return ErrNoReturn
+	}
+	return unmarshalChecked(buf, out)
 }
 
-// MoveTo sets your current location
+func getPlatformReturn(response *protos.ResponseEnvelope, requestType protos.PlatformRequestType) ([]byte, bool) {
+	for _, platformReturn := range response.PlatformReturns {
+		if platformReturn.Type == requestType {
+			return platformReturn.Response, true
+		}
+	}
+	return nil, false
+}
+
+// SetAccuracyModel makes every request report an accuracy sampled between min and max meters
+// instead of the accuracy of the location
+func (s *Session) SetAccuracyModel(min, max float64) {
+	if max < min {
+		min, max = max, min
+	}
+	s.accuracy = &accuracyModel{min: min, max: max}
+}
+
+func (s *Session) getAccuracy() float64 {
+	if s.accuracy != nil {
+		return s.accuracy.sample(s.randFloat64())
+	}
+	return s.location.Accuracy
+}
+
+// MoveTo sets your current location as a new location fix, the time since the last
+// location fix sent with the requests starts over and the fix is sent along with the next signature.
+// With an altitude provider set the altitude of the location is filled in from it
 func (s *Session) MoveTo(location *Location) {
+	s.lookupAltitude(location)
+	now := time.Now()
+	s.callMu.Lock()
+	s.movement.update(s.location, location, now)
 	s.location = location
+	s.callMu.Unlock()
+	s.recordLocationFix(now)
 }
 
-// Init initializes the client by performing full authentication
-func (s *Session) Init(ctx context.Context, proxyId int64) error {
-	_, err := s.provider.Login(ctx)
+// AdjustLocation corrects your current location, like its altitude, without making a new location fix.
+// Unlike MoveTo it doesn't affect the time since the last location fix or the movement speed
+func (s *Session) AdjustLocation(location *Location) {
+	s.callMu.Lock()
+	s.location = location
+	s.callMu.Unlock()
+}
+
+// getLocation returns the current location, which calls running at the same time may be moving
+func (s *Session) getLocation() *Location {
+	s.callMu.Lock()
+	defer s.callMu.Unlock()
+	return s.location
+}
+
+// SetActivityStatus overrides the activity status sent in the signature,
+// when nil the activity status is derived from the speed between the last moves
+func (s *Session) SetActivityStatus(status *protos.Signature_ActivityStatus) {
+	s.activity = status
+}
+
+func (s *Session) getActivityStatus() *protos.Signature_ActivityStatus {
+	if s.activity != nil {
+		return s.activity
+	}
+	return s.movement.activityStatus(time.Now())
+}
+
+// SetAuthTicket resumes the session with a previously received auth ticket instead of performing Init,
+// apiURL is the API url the remote service responded with together with the ticket
+func (s *Session) SetAuthTicket(ticket *protos.AuthTicket, apiURL string) error {
+	if ticket == nil {
+		return ErrNoAuthTicket
+	}
+	if apiURL == "" {
+		return ErrNoURL
+	}
+	if ticket.ExpireTimestampMs < getTimestamp(time.Now()) {
+		return ErrTicketExpired
+	}
+
+	err := s.generateSessionHash()
 	if err != nil {
 		return err
 	}
 
-	_, err = rand.Read(s.hash)
+	s.setURL(apiURL)
+	s.setTicket(ticket)
+
+	return nil
+}
+
+// SetSessionHash sets the session hash sent in the signature, so a restored session keeps signing with the same hash.
+// Init and SetAuthTicket keep a hash set this way instead of generating a new one
+func (s *Session) SetSessionHash(hash []byte) error {
+	if len(hash) != len(s.hash) {
+		return fmt.Errorf("The session hash must be %d bytes, got %d", len(s.hash), len(hash))
+	}
+	copy(s.hash, hash)
+	s.hashSet = true
+	return nil
+}
+
+// generateSessionHash fills the session hash with random bytes, unless it has been set with SetSessionHash
+func (s *Session) generateSessionHash() error {
+	if s.hashSet {
+		return nil
+	}
+	_, err := io.ReadFull(s.random, s.hash)
 	if err != nil {
 		return ErrFormatting
 	}
+	return nil
+}
 
-	settingsMessage, _ := proto.Marshal(&protos.DownloadSettingsMessage{
-		Hash: downloadSettingsHash,
-	})
+// SetInitTimeouts sets separate timeouts for the login with the auth provider and the first call made by Init,
+// a zero timeout leaves the step bound only by the context passed to Init
+func (s *Session) SetInitTimeouts(login, call time.Duration) {
+	s.loginTimeout = login
+	s.initTimeout = call
+}
 
-	requests := []*protos.Request{
-		{RequestType: protos.RequestType_GET_PLAYER},
-		{RequestType: protos.RequestType_GET_HATCHED_EGGS},
-		{RequestType: protos.RequestType_GET_INVENTORY},
-		{RequestType: protos.RequestType_CHECK_AWARDED_BADGES},
-		{protos.RequestType_DOWNLOAD_SETTINGS, settingsMessage},
-		// {RequestType: protos.RequestType_CHECK_CHALLENGE},
+func withOptionalTimeout(ctx context.Context, d time.Duration) (context.Context, context.CancelFunc) {
+	if d > 0 {
+		return context.WithTimeout(ctx, d)
 	}
+	return context.WithCancel(ctx)
+}
 
-	response, err := s.Call(ctx, requests, proxyId)
+// AuthExpiry returns the time the access token of the last login expires, so the next Init can be scheduled
+// before it does, it is the zero time when the provider does not implement auth.ExpiringProvider
+func (s *Session) AuthExpiry() time.Time {
+	return s.authExpiry
+}
+
+// Init initializes the client by performing full authentication,
+// the session is only marked as initialized when all steps succeed
+func (s *Session) Init(ctx context.Context, proxyId int64) error {
+	s.callMu.Lock()
+	s.logins++
+	s.hasTicket = false
+	s.ticket = nil
+	s.ticketBytes = nil
+	s.url = ""
+	s.callMu.Unlock()
+
+	loginCtx, cancelLogin := withOptionalTimeout(ctx, s.loginTimeout)
+	_, err := s.provider.Login(loginCtx)
+	cancelLogin()
+	if err != nil {
+		return err
+	}
+	s.authExpiry = time.Time{}
+	if provider, ok := s.provider.(auth.ExpiringProvider); ok {
+		s.authExpiry = provider.GetExpiry()
+	}
+
+	err = s.generateSessionHash()
+	if err != nil {
+		return err
+	}
+
+	requests := s.commonRequests(nil)
+
+	callCtx, cancelCall := withOptionalTimeout(ctx, s.initTimeout)
+	response, err := s.Call(callCtx, requests, proxyId)
+	cancelCall()
 	if err != nil {
 		return err
 	}
@@ -249,34 +883,98 @@ func (s *Session) Init(ctx context.Context, proxyId int64) error {
 	if url == "" {
 		return ErrNoURL
 	}
-	s.setURL(url)
 
 	ticket := response.GetAuthTicket()
+	if ticket == nil {
+		return ErrNoAuthTicket
+	}
 
+	s.callMu.Lock()
+	s.setURL(url)
 	s.setTicket(ticket)
+	s.callMu.Unlock()
+	s.cachePlayer(requests, response)
+	s.cacheSettings(requests, response)
+	s.cacheRemoteConfig(requests, response)
 
 	return nil
 }
 
+// SetAutoRelogin makes calls that are answered with status code 102, an invalid auth token, log in with the
+// provider and perform Init again before they are retried once. Without it such calls give ErrInvalidAuthToken
+func (s *Session) SetAutoRelogin(relogin bool) {
+	s.autoRelogin = relogin
+}
+
+// relogin replaces the invalid auth ticket with a new one from a full login, logins is the number of logins
+// the session had made when the call answered with an invalid auth token was sent
+func (s *Session) relogin(ctx context.Context, proxyId int64, logins int) error {
+	s.recoveryMu.Lock()
+	defer s.recoveryMu.Unlock()
+
+	// Another call answered with an invalid auth token may have logged in again while this one waited
+	if s.loginCount() != logins {
+		return nil
+	}
+	return s.Init(withRecovery(ctx), proxyId)
+}
+
+func (s *Session) loginCount() int {
+	s.callMu.Lock()
+	defer s.callMu.Unlock()
+	return s.logins
+}
+
 // Announce publishes the player's presence and returns the map environment
-func (s *Session) Announce(ctx context.Context, proxyId int64) (mapObjects *protos.GetMapObjectsResponse, err error) {
-	cellIDs := s.location.GetCellIDs()
-	lastTimestamp := time.Now().Unix() * 1000
+func (s *Session) Announce(ctx context.Context, proxyId int64, options ...MapOption) (*protos.GetMapObjectsResponse, error) {
+	o := newMapOptions(options)
+	mapObjects, _, err := s.announce(ctx, proxyId, o, nil)
+	if err == nil && o.emptyMapRetry > 0 && IsEmptyMap(mapObjects) {
+		select {
+		case <-time.After(o.emptyMapRetry):
+		case <-ctx.Done():
+			return mapObjects, ctx.Err()
+		}
+		mapObjects, _, err = s.announce(ctx, proxyId, o, nil)
+	}
+	return mapObjects, err
+}
 
-	settingsMessage, _ := proto.Marshal(&protos.DownloadSettingsMessage{
-		Hash: downloadSettingsHash,
-	})
+// AnnounceWith works like Announce but sends the extra requests in the same call,
+// their returns are given keyed by request type
+func (s *Session) AnnounceWith(ctx context.Context, extra []*protos.Request, proxyId int64) (*protos.GetMapObjectsResponse, map[protos.RequestType][]byte, error) {
+	return s.announce(ctx, proxyId, newMapOptions(nil), extra)
+}
+
+func (s *Session) announce(ctx context.Context, proxyId int64, o *mapOptions, extra []*protos.Request) (mapObjects *protos.GetMapObjectsResponse, extraReturns map[protos.RequestType][]byte, err error) {
+	location := s.getLocation()
+	cellIDs := location.GetCellIDs()
+	err = s.checkCellCount(cellIDs)
+	if err != nil {
+		return nil, nil, err
+	}
+	s.callMu.Lock()
+	sinceTimestamps := s.mapTimestamps(cellIDs)
+	if s.cellShuffle != nil {
+		shuffleCells(s.cellShuffle, cellIDs, sinceTimestamps)
+	}
+	err = s.moveGate.check(location)
+	s.callMu.Unlock()
+	if err != nil {
+		return nil, nil, err
+	}
+	lastTimestamp := time.Now().Unix() * 1000
 
 	getMapObjs := &protos.GetMapObjectsMessage{
 		// Traversed route since last supposed last heartbeat
 		CellId: cellIDs,
 
 		// Timestamps in milliseconds corresponding to each route cell id
-		SinceTimestampMs: make([]int64, len(cellIDs)),
+		SinceTimestampMs: sinceTimestamps,
 
 		// Current longitide and latitude
-		Longitude: s.location.Lon,
-		Latitude:  s.location.Lat,
+		Longitude: location.Lon,
+		Latitude:  location.Lat,
 	}
 
 	// Request the map objects based on my current location and route cell ids
@@ -288,52 +986,96 @@ func (s *Session) Announce(ctx context.Context, proxyId int64) (mapObjects *prot
 	getInventoryMessage, _ := proto.Marshal(&protos.GetInventoryMessage{
 		LastTimestampMs: lastTimestamp,
 	})
-	requests := []*protos.Request{
-		{RequestType: protos.RequestType_GET_PLAYER},
-		{RequestType: protos.RequestType_GET_HATCHED_EGGS},
-		{protos.RequestType_GET_INVENTORY, getInventoryMessage},
-		{RequestType: protos.RequestType_CHECK_AWARDED_BADGES},
-		{protos.RequestType_DOWNLOAD_SETTINGS, settingsMessage},
-		{protos.RequestType_GET_MAP_OBJECTS, getMapObjectsMessage},
-		{RequestType: protos.RequestType_CHECK_CHALLENGE},
+	requests := append(s.commonRequests(getInventoryMessage), &protos.Request{
+		RequestType:    protos.RequestType_GET_MAP_OBJECTS,
+		RequestMessage: getMapObjectsMessage,
+	})
+	if s.announceCheckChallenge {
+		requests = append(requests, &protos.Request{RequestType: protos.RequestType_CHECK_CHALLENGE})
+	}
+	extraOffset := len(requests)
+	requests = append(requests, extra...)
+
+	err = s.waitForMapRefresh(ctx)
+	if err != nil {
+		return nil, nil, err
 	}
 
 	response, err := s.Call(ctx, requests, proxyId)
 	if err != nil {
-		if err == ErrProxyDead {
-			return mapObjects, err
-		}
-		return mapObjects, ErrRequest
+		return nil, nil, err
 	}
 
-	mapObjects = &protos.GetMapObjectsResponse{}
-	if len(response.Returns) < 5 {
-		return nil, errors.New("Empty response")
+	s.cachePlayer(requests, response)
+	s.cacheSettings(requests, response)
+	s.cacheRemoteConfig(requests, response)
+
+	mapObjectsReturn, ok := getReturn(requests, response, protos.RequestType_GET_MAP_OBJECTS)
+	if !ok {
+		return nil, nil, errors.New("Empty response")
 	}
-	err = proto.Unmarshal(response.Returns[5], mapObjects)
+	mapObjects = &protos.GetMapObjectsResponse{}
+	err = unmarshalChecked(mapObjectsReturn, mapObjects)
 	if err != nil {
-		return nil, &ErrResponse{err}
+		return nil, nil, err
+	}
+	captured := time.Now()
+	s.callMu.Lock()
+	s.lastMapTime = captured
+	s.trackMapTimestamps(mapObjects)
+	softBanErr := s.softBan.track(mapObjects)
+	changedCells := s.mergeMapCells(mapObjects)
+	s.callMu.Unlock()
+	o.apply(mapObjects)
+	if s.stripEmptyCells {
+		StripEmptyCells(mapObjects)
+	}
+	if s.changedCellsFeed {
+		changed := &protos.GetMapObjectsResponse{Status: mapObjects.Status, MapCells: changedCells}
+		o.filter(changed)
+		if s.stripEmptyCells {
+			StripEmptyCells(changed)
+		}
+		s.pushMap(changed, captured)
+	} else {
+		s.pushMap(mapObjects, captured)
 	}
-	s.feed.Push(mapObjects)
-	s.debugProtoMessage("response return[5]", mapObjects)
+	s.debugProtoMessage("response get map objects", mapObjects)
 
-	challenge := protos.CheckChallengeResponse{}
-	err = proto.Unmarshal(response.Returns[0], &challenge)
-	if challenge.ShowChallenge {
-		if strings.Contains(challenge.ChallengeUrl, "new RPC url") {
+	if len(extra) > 0 {
+		extraReturns = make(map[protos.RequestType][]byte, len(extra))
+		for idx, request := range extra {
+			if extraOffset+idx < len(response.Returns) {
+				extraReturns[request.RequestType] = response.Returns[extraOffset+idx]
+			}
+		}
+	}
+
+	if s.announceCheckChallenge && s.HasChallenge() {
+		if strings.Contains(s.ChallengeURL(), "new RPC url") {
 			s.setURL(response.ApiUrl)
 		}
-		return mapObjects, nil
+		return mapObjects, extraReturns, nil
+	}
+
+	if softBanErr != nil {
+		return mapObjects, extraReturns, softBanErr
 	}
 
-	return mapObjects, GetErrorFromStatus(response.StatusCode)
+	return mapObjects, extraReturns, newErrCall(requests, response, GetErrorFromStatus(response.StatusCode))
 }
 
+// CheckChallenge checks whether the account has to solve a ReCaptcha challenge, the request is sent without a proxy
 func (s *Session) CheckChallenge(ctx context.Context) (*protos.CheckChallengeResponse, error) {
+	return s.CheckChallengeWithProxy(ctx, -1)
+}
+
+// CheckChallengeWithProxy works like CheckChallenge but sends the request through the given proxy
+func (s *Session) CheckChallengeWithProxy(ctx context.Context, proxyId int64) (*protos.CheckChallengeResponse, error) {
 	requests := []*protos.Request{
 		{RequestType: protos.RequestType_CHECK_CHALLENGE},
 	}
-	response, err := s.Call(ctx, requests, -1)
+	response, err := s.Call(ctx, requests, proxyId)
 	if err != nil {
 		return nil, err
 	}
@@ -343,17 +1085,24 @@ func (s *Session) CheckChallenge(ctx context.Context) (*protos.CheckChallengeRes
 	}
 
 	challenge := &protos.CheckChallengeResponse{}
-	err = proto.Unmarshal(response.Returns[0], challenge)
+	err = unmarshalChecked(response.Returns[0], challenge)
 	if err != nil {
-		return nil, &ErrResponse{err}
+		return nil, err
 	}
 	s.feed.Push(challenge)
 	s.debugProtoMessage("response return[0]", challenge)
 
-	return challenge, GetErrorFromStatus(response.StatusCode)
+	return challenge, newErrCall(requests, response, GetErrorFromStatus(response.StatusCode))
 }
 
+// SolveCaptcha submits the solution of a ReCaptcha challenge, the request is sent without a proxy
 func (s *Session) SolveCaptcha(ctx context.Context, solution string) (*protos.VerifyChallengeResponse, error) {
+	return s.SolveCaptchaWithProxy(ctx, solution, -1)
+}
+
+// SolveCaptchaWithProxy works like SolveCaptcha but sends the requests through the given proxy,
+// which should be the proxy the account is used with
+func (s *Session) SolveCaptchaWithProxy(ctx context.Context, solution string, proxyId int64) (*protos.VerifyChallengeResponse, error) {
 	requestMessage, err := proto.Marshal(&protos.VerifyChallengeMessage{
 		Token: solution,
 	})
@@ -362,7 +1111,7 @@ func (s *Session) SolveCaptcha(ctx context.Context, solution string) (*protos.Ve
 	}
 
 	requests := []*protos.Request{{RequestType: protos.RequestType_VERIFY_CHALLENGE, RequestMessage: requestMessage}}
-	response, err := s.Call(ctx, requests, -1)
+	response, err := s.Call(ctx, requests, proxyId)
 	if err != nil {
 		return nil, err
 	}
@@ -372,35 +1121,82 @@ func (s *Session) SolveCaptcha(ctx context.Context, solution string) (*protos.Ve
 	}
 
 	challenge := &protos.VerifyChallengeResponse{}
-	err = proto.Unmarshal(response.Returns[0], challenge)
+	err = unmarshalChecked(response.Returns[0], challenge)
 	if err != nil {
-		return nil, &ErrResponse{err}
+		return nil, err
 	}
 	s.feed.Push(challenge)
 	s.debugProtoMessage("response return[0]", challenge)
 
-	return challenge, GetErrorFromStatus(response.StatusCode)
+	err = newErrCall(requests, response, GetErrorFromStatus(response.StatusCode))
+	if err != nil {
+		return challenge, err
+	}
+	if !challenge.Success {
+		return challenge, ErrCaptchaFailed
+	}
+	s.clearChallenge()
+
+	// Make sure the challenge has actually been cleared
+	check, err := s.CheckChallengeWithProxy(ctx, proxyId)
+	if err != nil {
+		return challenge, err
+	}
+	if check.ShowChallenge {
+		return challenge, ErrCheckChallenge
+	}
+
+	return challenge, nil
 }
 
+// GetPlayer returns the player data, within the player cache window the cached player data is returned
 func (s *Session) GetPlayer(ctx context.Context, proxyId int64) (*protos.GetPlayerResponse, error) {
-	requests := []*protos.Request{{RequestType: protos.RequestType_GET_PLAYER}}
+	if player, ok := s.playerCache.get(); ok {
+		return player, nil
+	}
+	return s.RefreshPlayer(ctx, proxyId)
+}
+
+// RefreshPlayer requests the player data regardless of the player cache
+func (s *Session) RefreshPlayer(ctx context.Context, proxyId int64) (*protos.GetPlayerResponse, error) {
+	requests := []*protos.Request{getPlayerRequest}
 	response, err := s.Call(ctx, requests, proxyId)
 	if err != nil {
 		return nil, err
 	}
 
+	if len(response.Returns) < 1 {
+		return nil, errors.New("Empty response")
+	}
+
 	player := &protos.GetPlayerResponse{}
-	err = proto.Unmarshal(response.Returns[0], player)
+	err = unmarshalChecked(response.Returns[0], player)
 	if err != nil {
-		return nil, &ErrResponse{err}
+		return nil, err
 	}
+	s.playerCache.set(player)
 	s.feed.Push(player)
 	s.debugProtoMessage("response return[0]", player)
 
-	return player, GetErrorFromStatus(response.StatusCode)
+	return player, newErrCall(requests, response, GetErrorFromStatus(response.StatusCode))
 }
 
+// Encounter starts an encounter with a pokémon. The player position sent with the encounter has to match the
+// location of the session, move there with MoveTo first. loc may be nil to use the location of the session,
+// a position further than maxPositionDrift from it gives ErrPositionMismatch without sending a request
 func (s *Session) Encounter(ctx context.Context, encounterID uint64, spawnID string, loc *Location, proxyId int64) (*protos.EncounterResponse, error) {
+	spawnID, err := normalizeSpawnID(spawnID)
+	if err != nil {
+		return nil, err
+	}
+	location := s.getLocation()
+	if loc == nil {
+		loc = location
+	}
+	if location.DistanceTo(loc) > maxPositionDrift {
+		return nil, ErrPositionMismatch
+	}
+
 	requestMessage, err := proto.Marshal(&protos.EncounterMessage{
 		EncounterId:     encounterID,
 		SpawnPointId:    spawnID,
@@ -422,19 +1218,19 @@ func (s *Session) Encounter(ctx context.Context, encounterID uint64, spawnID str
 	}
 
 	encounter := &protos.EncounterResponse{}
-	err = proto.Unmarshal(response.Returns[0], encounter)
+	err = unmarshalChecked(response.Returns[0], encounter)
 	if err != nil {
-		return nil, &ErrResponse{err}
+		return nil, err
 	}
 	s.feed.Push(encounter)
 	s.debugProtoMessage("response return[0]", encounter)
 
-	return encounter, GetErrorFromStatus(response.StatusCode)
+	return encounter, newErrCall(requests, response, GetErrorFromStatus(response.StatusCode))
 }
 
 // GetPlayerMap returns the surrounding map cells
-func (s *Session) GetPlayerMap(ctx context.Context, proxyId int64) (*protos.GetMapObjectsResponse, error) {
-	return s.Announce(ctx, proxyId)
+func (s *Session) GetPlayerMap(ctx context.Context, proxyId int64, options ...MapOption) (*protos.GetMapObjectsResponse, error) {
+	return s.Announce(ctx, proxyId, options...)
 }
 
 // GetInventory returns the player items
@@ -444,13 +1240,237 @@ func (s *Session) GetInventory(ctx context.Context, proxyId int64) (*protos.GetI
 	if err != nil {
 		return nil, err
 	}
+	if len(response.Returns) < 1 {
+		return nil, errors.New("Empty response")
+	}
+
 	inventory := &protos.GetInventoryResponse{}
-	err = proto.Unmarshal(response.Returns[0], inventory)
+	err = unmarshalChecked(response.Returns[0], inventory)
 	if err != nil {
-		return nil, &ErrResponse{err}
+		return nil, err
 	}
+	s.trackBag(inventory)
+	s.trackCandy(inventory)
 	s.feed.Push(inventory)
 	s.debugProtoMessage("response return[0]", inventory)
 
-	return inventory, GetErrorFromStatus(response.StatusCode)
+	return inventory, newErrCall(requests, response, GetErrorFromStatus(response.StatusCode))
+}
+
+// GetStoreItems returns the items listed in the in-app store
+func (s *Session) GetStoreItems(ctx context.Context, proxyId int64) ([]*protos.GetStoreItemsResponse_StoreItem, error) {
+	platformRequests := []*protos.RequestEnvelope_PlatformRequest{{Type: protos.PlatformRequestType_GET_STORE_ITEMS}}
+	response, err := s.Call(withPlatformRequests(ctx, platformRequests), nil, proxyId)
+	if err != nil {
+		return nil, newPlatformErrCall(platformRequests, response, err)
+	}
+
+	platformReturn, ok := getPlatformReturn(response, protos.PlatformRequestType_GET_STORE_ITEMS)
+	if !ok {
+		return nil, errors.New("Empty response")
+	}
+
+	storeItems := &protos.GetStoreItemsResponse{}
+	err = unmarshalChecked(platformReturn, storeItems)
+	if err != nil {
+		return nil, err
+	}
+	s.feed.Push(storeItems)
+	s.debugProtoMessage("response platform return", storeItems)
+
+	return storeItems.Items, newPlatformErrCall(platformRequests, response, GetErrorFromStatus(response.StatusCode))
+}
+
+// DownloadRemoteConfigVersion returns the timestamps of the current item templates and asset digest
+func (s *Session) DownloadRemoteConfigVersion(ctx context.Context, proxyId int64) (*protos.DownloadRemoteConfigVersionResponse, error) {
+	requests := []*protos.Request{s.getRemoteConfigRequest()}
+	response, err := s.Call(ctx, requests, proxyId)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(response.Returns) < 1 {
+		return nil, errors.New("Empty response")
+	}
+
+	remoteConfig := &protos.DownloadRemoteConfigVersionResponse{}
+	err = unmarshalChecked(response.Returns[0], remoteConfig)
+	if err != nil {
+		return nil, err
+	}
+	s.itemTemplatesTimestamp = remoteConfig.ItemTemplatesTimestampMs
+	s.remoteConfigSeen = true
+	s.feed.Push(remoteConfig)
+	s.debugProtoMessage("response return[0]", remoteConfig)
+
+	return remoteConfig, newErrCall(requests, response, GetErrorFromStatus(response.StatusCode))
+}
+
+// RecycleInventoryItem discards the given amount of an item from the inventory
+func (s *Session) RecycleInventoryItem(ctx context.Context, itemID protos.ItemId, count int32, proxyId int64) (*protos.RecycleInventoryItemResponse, error) {
+	requestMessage, err := proto.Marshal(&protos.RecycleInventoryItemMessage{
+		ItemId: itemID,
+		Count:  count,
+	})
+	if err != nil {
+		return nil, ErrFormatting
+	}
+
+	requests := []*protos.Request{{RequestType: protos.RequestType_RECYCLE_INVENTORY_ITEM, RequestMessage: requestMessage}}
+	response, err := s.Call(ctx, requests, proxyId)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(response.Returns) < 1 {
+		return nil, errors.New("Empty response")
+	}
+
+	recycle := &protos.RecycleInventoryItemResponse{}
+	err = unmarshalChecked(response.Returns[0], recycle)
+	if err != nil {
+		return nil, err
+	}
+	s.trackBagRecycle(itemID, recycle)
+	s.feed.Push(recycle)
+	s.debugProtoMessage("response return[0]", recycle)
+
+	return recycle, newErrCall(requests, response, GetErrorFromStatus(response.StatusCode))
+}
+
+// RecycleItemsToTarget discards items from the inventory until the targeted counts are reached,
+// the recycle requests are spaced out and the results are returned by item id
+func (s *Session) RecycleItemsToTarget(ctx context.Context, targets map[protos.ItemId]int32, inventory *protos.GetInventoryResponse, proxyId int64) (map[protos.ItemId]*protos.RecycleInventoryItemResponse, error) {
+	results := make(map[protos.ItemId]*protos.RecycleInventoryItemResponse)
+	counts := ItemCounts(inventory)
+
+	first := true
+	for itemID, target := range targets {
+		excess := counts[itemID] - target
+		if excess <= 0 {
+			continue
+		}
+
+		if !first {
+			select {
+			case <-time.After(s.pause(recycleInterval)):
+			case <-ctx.Done():
+				return results, ctx.Err()
+			}
+		}
+		first = false
+
+		recycle, err := s.RecycleInventoryItem(ctx, itemID, excess, proxyId)
+		if err != nil {
+			return results, err
+		}
+		results[itemID] = recycle
+	}
+
+	return results, nil
+}
+
+// UseItemCapture uses an item like a razz berry on an encountered pokémon, it is meant to be used
+// before throwing a ball. The response contains the capture and flee probability multipliers of the item
+func (s *Session) UseItemCapture(ctx context.Context, encounterID uint64, spawnID string, itemID protos.ItemId, proxyId int64) (*protos.UseItemCaptureResponse, error) {
+	spawnID, err := normalizeSpawnID(spawnID)
+	if err != nil {
+		return nil, err
+	}
+
+	requestMessage, err := proto.Marshal(&protos.UseItemCaptureMessage{
+		ItemId:       itemID,
+		EncounterId:  encounterID,
+		SpawnPointId: spawnID,
+	})
+	if err != nil {
+		return nil, ErrFormatting
+	}
+
+	requests := []*protos.Request{{RequestType: protos.RequestType_USE_ITEM_CAPTURE, RequestMessage: requestMessage}}
+	response, err := s.Call(ctx, requests, proxyId)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(response.Returns) < 1 {
+		return nil, errors.New("Empty response")
+	}
+
+	useItem := &protos.UseItemCaptureResponse{}
+	err = unmarshalChecked(response.Returns[0], useItem)
+	if err != nil {
+		return nil, err
+	}
+	s.feed.Push(useItem)
+	s.debugProtoMessage("response return[0]", useItem)
+
+	return useItem, newErrCall(requests, response, GetErrorFromStatus(response.StatusCode))
+}
+
+// FortSearch spins a Pokéstop from the current location, a fort that was searched
+// by the session less than its cooldown ago gives ErrFortCooldown and a fort outside
+// the interaction range gives ErrFortOutOfRange without sending a request
+func (s *Session) FortSearch(ctx context.Context, fort *protos.FortData, proxyId int64) (*protos.FortSearchResponse, error) {
+	fortID, err := normalizeFortID(fort.Id)
+	if err != nil {
+		return nil, err
+	}
+	if s.FortCooldownRemaining(fortID) > 0 {
+		return nil, ErrFortCooldown
+	}
+	location := s.getLocation()
+	if location.DistanceToFort(fort) > s.FortInteractionRange() {
+		return nil, ErrFortOutOfRange
+	}
+	err = s.checkBag()
+	if err != nil {
+		return nil, err
+	}
+
+	requestMessage, err := proto.Marshal(&protos.FortSearchMessage{
+		FortId:          fortID,
+		PlayerLatitude:  location.Lat,
+		PlayerLongitude: location.Lon,
+		FortLatitude:    fort.Latitude,
+		FortLongitude:   fort.Longitude,
+	})
+	if err != nil {
+		return nil, ErrFormatting
+	}
+
+	requests := []*protos.Request{{RequestType: protos.RequestType_FORT_SEARCH, RequestMessage: requestMessage}}
+	response, err := s.Call(ctx, requests, proxyId)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(response.Returns) < 1 {
+		return nil, errors.New("Empty response")
+	}
+
+	fortSearch := &protos.FortSearchResponse{}
+	err = unmarshalChecked(response.Returns[0], fortSearch)
+	if err != nil {
+		return nil, err
+	}
+	s.trackFortCooldown(fortID, fortSearch)
+	s.trackItemsGained(fortSearch.ItemsAwarded)
+	s.trackBagAwards(fortSearch.ItemsAwarded)
+	s.feed.Push(fortSearch)
+	s.debugProtoMessage("response return[0]", fortSearch)
+
+	return fortSearch, newErrCall(requests, response, GetErrorFromStatus(response.StatusCode))
+}
+
+// Ping measures the round trip time of a minimal request through the given proxy
+func (s *Session) Ping(ctx context.Context, proxyId int64) (time.Duration, error) {
+	start := time.Now()
+	requests := []*protos.Request{getPlayerRequest}
+	response, err := s.Call(ctx, requests, proxyId)
+	elapsed := time.Since(start)
+	if err != nil {
+		return elapsed, err
+	}
+	return elapsed, newErrCall(requests, response, GetErrorFromStatus(response.StatusCode))
 }
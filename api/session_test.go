@@ -0,0 +1,378 @@
+package api
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"golang.org/x/net/context"
+
+	protos "github.com/pogodevorg/POGOProtos-go"
+)
+
+// testProvider is an auth provider that logs in without a remote service
+type testProvider struct {
+	mu     sync.Mutex
+	logins int
+}
+
+func (p *testProvider) Login(ctx context.Context) (string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.logins++
+	return "token", nil
+}
+
+func (p *testProvider) GetProviderString() string {
+	return "ptc"
+}
+
+func (p *testProvider) GetAccessToken() string {
+	return "token"
+}
+
+func (p *testProvider) loginCount() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.logins
+}
+
+// fakeTransport answers the requests of the RPC client in place of the remote service
+type fakeTransport struct {
+	mu        sync.Mutex
+	respond   func(request *protos.RequestEnvelope) *protos.ResponseEnvelope
+	envelopes []*protos.RequestEnvelope
+	urls      []string
+}
+
+func (f *fakeTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	body, err := ioutil.ReadAll(req.Body)
+	if err != nil {
+		return nil, err
+	}
+	envelope := &protos.RequestEnvelope{}
+	err = proto.Unmarshal(body, envelope)
+	if err != nil {
+		return nil, err
+	}
+
+	f.mu.Lock()
+	f.envelopes = append(f.envelopes, envelope)
+	f.urls = append(f.urls, req.URL.String())
+	f.mu.Unlock()
+
+	out, err := proto.Marshal(f.respond(envelope))
+	if err != nil {
+		return nil, err
+	}
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     make(http.Header),
+		Body:       ioutil.NopCloser(bytes.NewReader(out)),
+		Request:    req,
+	}, nil
+}
+
+func (f *fakeTransport) requestCount() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.envelopes)
+}
+
+func (f *fakeTransport) lastEnvelope() *protos.RequestEnvelope {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if len(f.envelopes) == 0 {
+		return nil
+	}
+	return f.envelopes[len(f.envelopes)-1]
+}
+
+// newTestSession constructs a session whose calls are answered by respond, it sends unsigned requests
+// without pauses between them
+func newTestSession(respond func(request *protos.RequestEnvelope) *protos.ResponseEnvelope) (*Session, *fakeTransport, *testProvider) {
+	transport := &fakeTransport{respond: respond}
+	provider := &testProvider{}
+	s := NewSessionWithOptions(
+		WithProvider(provider),
+		WithLocation(&Location{Lat: 51.5074, Lon: -0.1278, Accuracy: defaultAccuracy}),
+		WithHTTPClient(&http.Client{Transport: transport}),
+	)
+	s.SetSendSignature(false)
+	s.SetJitter(0)
+	return s, transport, provider
+}
+
+// testTicket returns an auth ticket that is valid for another hour
+func testTicket() *protos.AuthTicket {
+	return &protos.AuthTicket{
+		Start:             []byte("start"),
+		ExpireTimestampMs: getTimestamp(time.Now().Add(time.Hour)),
+		End:               []byte("end"),
+	}
+}
+
+// okResponse answers every request of the envelope with an empty return
+func okResponse(request *protos.RequestEnvelope) *protos.ResponseEnvelope {
+	return &protos.ResponseEnvelope{
+		StatusCode: protos.ResponseEnvelope_OK,
+		RequestId:  request.RequestId,
+		Returns:    make([][]byte, len(request.Requests)),
+	}
+}
+
+func TestAnnounceWithFiveReturns(t *testing.T) {
+	s, _, _ := newTestSession(func(request *protos.RequestEnvelope) *protos.ResponseEnvelope {
+		return &protos.ResponseEnvelope{
+			StatusCode: protos.ResponseEnvelope_OK,
+			RequestId:  request.RequestId,
+			Returns:    make([][]byte, 5),
+		}
+	})
+	s.setTicket(testTicket())
+
+	mapObjects, err := s.Announce(context.Background(), -1)
+	if err == nil {
+		t.Fatal("Announce succeeded on a response without the map objects return")
+	}
+	if mapObjects != nil {
+		t.Errorf("Announce returned map objects %v on a response without the map objects return", mapObjects)
+	}
+}
+
+func TestFailedInitLeavesCleanState(t *testing.T) {
+	s, _, provider := newTestSession(okResponse)
+	s.setURL("pgorelease.example/plfe/1")
+	s.setTicket(testTicket())
+
+	err := s.Init(context.Background(), -1)
+	if err != ErrNoURL {
+		t.Fatalf("Init gave %v, expected %v", err, ErrNoURL)
+	}
+	if provider.loginCount() != 1 {
+		t.Errorf("Init logged in %d times, expected once", provider.loginCount())
+	}
+	if s.hasTicket || s.ticket != nil || s.ticketBytes != nil {
+		t.Error("The session kept an auth ticket after a failed Init")
+	}
+	if s.url != "" {
+		t.Errorf("The session kept the API url %q after a failed Init", s.url)
+	}
+	if !s.IsExpired() {
+		t.Error("The session is not expired after a failed Init")
+	}
+}
+
+// testHasher gives fixed hashes, so calls can be signed without a signer
+type testHasher struct{}
+
+func (h testHasher) Hash(ctx context.Context, version uint64, timestamp uint64, location *Location, authTicket, sessionHash []byte, requests [][]byte) (*Hashes, error) {
+	return &Hashes{RequestHash: make([]uint64, len(requests))}, nil
+}
+
+func BenchmarkCall(b *testing.B) {
+	s, _, _ := newTestSession(okResponse)
+	s.SetHasher(testHasher{})
+	s.SetSendSignature(true)
+	s.setURL("pgorelease.example/plfe/1")
+	s.setTicket(testTicket())
+
+	requests := append(s.commonRequests(nil),
+		&protos.Request{RequestType: protos.RequestType_GET_MAP_OBJECTS},
+		&protos.Request{RequestType: protos.RequestType_CHECK_CHALLENGE},
+	)
+	ctx := context.Background()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_, err := s.Call(ctx, requests, -1)
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestCallAlwaysRedirected(t *testing.T) {
+	s, transport, _ := newTestSession(func(request *protos.RequestEnvelope) *protos.ResponseEnvelope {
+		return &protos.ResponseEnvelope{
+			StatusCode: protos.ResponseEnvelope_REDIRECT,
+			RequestId:  request.RequestId,
+			ApiUrl:     "pgorelease.example/plfe/2",
+		}
+	})
+	s.setURL("pgorelease.example/plfe/1")
+	s.setTicket(testTicket())
+
+	_, err := s.Call(context.Background(), []*protos.Request{getPlayerRequest}, -1)
+	if !errors.Is(err, ErrTooManyRedirects) {
+		t.Fatalf("Call gave %v, expected %v", err, ErrTooManyRedirects)
+	}
+	if transport.requestCount() != maxRedirects+1 {
+		t.Errorf("Call sent %d requests, expected %d", transport.requestCount(), maxRedirects+1)
+	}
+}
+
+func TestNilSignerGivesErrNoSigner(t *testing.T) {
+	s, transport, _ := newTestSession(okResponse)
+	s.SetSendSignature(true)
+	s.setURL("pgorelease.example/plfe/1")
+	s.setTicket(testTicket())
+
+	_, err := s.RefreshPlayer(context.Background(), -1)
+	if !errors.Is(err, ErrNoSigner) {
+		t.Fatalf("RefreshPlayer gave %v, expected %v", err, ErrNoSigner)
+	}
+	if transport.requestCount() != 0 {
+		t.Errorf("RefreshPlayer sent %d requests without a signer, expected none", transport.requestCount())
+	}
+}
+
+// reloginResponse answers calls with the stale auth ticket with an invalid auth token,
+// a login is answered with a fresh auth ticket
+func reloginResponse(request *protos.RequestEnvelope) *protos.ResponseEnvelope {
+	response := okResponse(request)
+	switch {
+	case request.AuthTicket == nil:
+		response.ApiUrl = "pgorelease.example/plfe/2"
+		response.AuthTicket = &protos.AuthTicket{
+			Start:             []byte("fresh"),
+			ExpireTimestampMs: getTimestamp(time.Now().Add(time.Hour)),
+			End:               []byte("end"),
+		}
+	case string(request.AuthTicket.Start) != "fresh":
+		response.StatusCode = protos.ResponseEnvelope_INVALID_AUTH_TOKEN
+		response.Returns = nil
+	}
+	return response
+}
+
+func TestInvalidAuthTokenLogsInAgain(t *testing.T) {
+	s, transport, provider := newTestSession(reloginResponse)
+	s.SetAutoRelogin(true)
+	s.setURL("pgorelease.example/plfe/1")
+	s.setTicket(testTicket())
+
+	response, err := s.Call(context.Background(), []*protos.Request{getPlayerRequest}, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if response.StatusCode != protos.ResponseEnvelope_OK {
+		t.Errorf("The retried call gave status code %d, expected %d", response.StatusCode, protos.ResponseEnvelope_OK)
+	}
+	if provider.loginCount() != 1 {
+		t.Errorf("The session logged in %d times, expected once", provider.loginCount())
+	}
+	last := transport.lastEnvelope()
+	if last.AuthTicket == nil || string(last.AuthTicket.Start) != "fresh" {
+		t.Errorf("The call was retried with auth ticket %v, expected the one of the new login", last.AuthTicket)
+	}
+}
+
+func TestConcurrentInvalidAuthTokensLogInOnce(t *testing.T) {
+	s, _, provider := newTestSession(reloginResponse)
+	s.SetAutoRelogin(true)
+	s.setURL("pgorelease.example/plfe/1")
+	s.setTicket(testTicket())
+
+	var wg sync.WaitGroup
+	errs := make([]error, 8)
+	for i := range errs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, errs[i] = s.Call(context.Background(), []*protos.Request{getPlayerRequest}, -1)
+		}(i)
+	}
+	wg.Wait()
+
+	for i, err := range errs {
+		if err != nil {
+			t.Errorf("Call %d gave %v", i, err)
+		}
+	}
+	if provider.loginCount() != 1 {
+		t.Errorf("The session logged in %d times for calls answered at the same time, expected once", provider.loginCount())
+	}
+}
+
+func TestSignatureBuilderCanUseSession(t *testing.T) {
+	s, transport, _ := newTestSession(okResponse)
+	s.SetHasher(testHasher{})
+	s.SetSendSignature(true)
+	s.setURL("pgorelease.example/plfe/1")
+	s.setTicket(testTicket())
+	s.SetSignatureBuilder(func(signature *protos.Signature) {
+		s.MoveTo(&Location{Lat: 51.5075, Lon: -0.1279, Accuracy: defaultAccuracy})
+	})
+
+	_, err := s.Call(context.Background(), []*protos.Request{getPlayerRequest}, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if transport.requestCount() != 1 {
+		t.Errorf("Call sent %d requests, expected 1", transport.requestCount())
+	}
+}
+
+func TestCloneForProvider(t *testing.T) {
+	s, _, _ := newTestSession(okResponse)
+	s.SetIncrementalMap(true)
+	s.SetTimestampedMapFeed(true)
+	s.SetTrackItemsGained(true)
+	s.SetShuffleCells(true, 1)
+
+	clone := s.CloneForProvider(&testProvider{}, &Location{Lat: 48.8566, Lon: 2.3522, Accuracy: defaultAccuracy})
+	if !clone.incrementalMap || !clone.timestampedMapFeed || !clone.trackItems {
+		t.Error("The clone did not get the map and item settings of the session")
+	}
+	if clone.cellShuffle == nil || clone.cellShuffle == s.cellShuffle {
+		t.Error("The clone does not shuffle the cells with a rand source of its own")
+	}
+	if clone.deviceInfo == s.deviceInfo || clone.deviceInfo.DeviceId == s.deviceInfo.DeviceId {
+		t.Errorf("The clone identifies as device %q like the session", clone.deviceInfo.DeviceId)
+	}
+	if clone.deviceInfo.DeviceModel != s.deviceInfo.DeviceModel {
+		t.Errorf("The clone identifies as a %q, expected a %q", clone.deviceInfo.DeviceModel, s.deviceInfo.DeviceModel)
+	}
+}
+
+func TestGetStoreItemsLogsInAgain(t *testing.T) {
+	s, transport, provider := newTestSession(reloginResponse)
+	s.SetAutoRelogin(true)
+	s.setURL("pgorelease.example/plfe/1")
+	s.setTicket(testTicket())
+
+	s.GetStoreItems(context.Background(), -1)
+	if provider.loginCount() != 1 {
+		t.Errorf("The session logged in %d times, expected once", provider.loginCount())
+	}
+	last := transport.lastEnvelope()
+	if last.AuthTicket == nil || string(last.AuthTicket.Start) != "fresh" {
+		t.Errorf("The store items were requested again with auth ticket %v, expected the one of the new login", last.AuthTicket)
+	}
+	if len(last.PlatformRequests) != 1 || last.PlatformRequests[0].Type != protos.PlatformRequestType_GET_STORE_ITEMS {
+		t.Errorf("The retried call sent platform requests %v, expected the store items request", last.PlatformRequests)
+	}
+	for _, envelope := range transport.envelopes {
+		if envelope.AuthTicket == nil && len(envelope.PlatformRequests) != 0 {
+			t.Errorf("The login sent the platform requests %v of the store items call", envelope.PlatformRequests)
+		}
+	}
+}
+
+func TestFortCooldownIgnoresCase(t *testing.T) {
+	s, _, _ := newTestSession(okResponse)
+	s.trackFortCooldown("47C3A7E8B1D.16", &protos.FortSearchResponse{Result: protos.FortSearchResponse_SUCCESS})
+
+	for _, fortID := range []string{"47C3A7E8B1D.16", "47c3a7e8b1d.16", " 47c3A7e8b1d.16 "} {
+		if s.FortCooldownRemaining(fortID) <= 0 {
+			t.Errorf("The fort %q is not in its cooldown", fortID)
+		}
+	}
+}
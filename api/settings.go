@@ -0,0 +1,71 @@
+package api
+
+import (
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"golang.org/x/net/context"
+
+	protos "github.com/pogodevorg/POGOProtos-go"
+)
+
+// defaultMapRefresh is the minimum time between map requests when the settings haven't been downloaded
+const defaultMapRefresh = 5 * time.Second
+
+// defaultInteractionRange is the distance in meters from which a fort can be used when the settings haven't been downloaded
+const defaultInteractionRange = 40.0
+
+// Settings returns the game settings last downloaded by the session, or nil if none have been received yet
+func (s *Session) Settings() *protos.GlobalSettings {
+	return s.settings
+}
+
+// cacheSettings stores the download settings return of a call, if there is one
+func (s *Session) cacheSettings(requests []*protos.Request, response *protos.ResponseEnvelope) {
+	settingsReturn, ok := getReturn(requests, response, protos.RequestType_DOWNLOAD_SETTINGS)
+	if !ok {
+		return
+	}
+	settings := &protos.DownloadSettingsResponse{}
+	if proto.Unmarshal(settingsReturn, settings) != nil {
+		return
+	}
+	// The settings are only sent along when the hash changed
+	if settings.Settings != nil {
+		s.settings = settings.Settings
+	}
+	if settings.Hash != "" && settings.Hash != s.downloadSettingsHash {
+		s.SetDownloadSettingsHash(settings.Hash)
+	}
+}
+
+func (s *Session) getMapRefresh() time.Duration {
+	if s.settings != nil && s.settings.MapSettings != nil && s.settings.MapSettings.GetMapObjectsMinRefreshSeconds > 0 {
+		return time.Duration(s.settings.MapSettings.GetMapObjectsMinRefreshSeconds * float32(time.Second))
+	}
+	return defaultMapRefresh
+}
+
+// FortInteractionRange returns the distance in meters from which a fort can be used according to the game settings
+func (s *Session) FortInteractionRange() float64 {
+	if s.settings != nil && s.settings.FortSettings != nil && s.settings.FortSettings.InteractionRangeMeters > 0 {
+		return s.settings.FortSettings.InteractionRangeMeters
+	}
+	return defaultInteractionRange
+}
+
+// waitForMapRefresh blocks until the minimum time between map requests has passed since the last one.
+// Every map request reserves its time, so map requests made at the same time are spread out as well
+func (s *Session) waitForMapRefresh(ctx context.Context) error {
+	s.callMu.Lock()
+	sendAt := time.Now()
+	if !s.lastMapRequest.IsZero() {
+		next := s.lastMapRequest.Add(s.getMapRefresh())
+		if next.After(sendAt) {
+			sendAt = next
+		}
+	}
+	s.lastMapRequest = sendAt
+	s.callMu.Unlock()
+	return waitUntil(ctx, sendAt)
+}
@@ -0,0 +1,67 @@
+package api
+
+import (
+	protos "github.com/pogodevorg/POGOProtos-go"
+)
+
+// initialRequestID is the request id of the first call of a session, every call after it uses the next id
+const initialRequestID uint64 = 8145806132888207460
+
+// SetRequestIDFunc replaces the built-in request id sequence, fn is called exactly once for every call
+// to give the id of its request envelope. A nil function restores the built-in sequence
+func (s *Session) SetRequestIDFunc(fn func() uint64) {
+	s.requestIDFunc = fn
+}
+
+// nextRequestID returns the request id of the next call, RPCID counts the calls made with the session
+func (s *Session) nextRequestID() uint64 {
+	id := initialRequestID + s.RPCID
+	s.RPCID++
+	if s.requestIDFunc != nil {
+		return s.requestIDFunc()
+	}
+	return id
+}
+
+// SessionSnapshot holds the state needed to resume a session after a restart without logging in again
+type SessionSnapshot struct {
+	AuthTicket  *protos.AuthTicket
+	APIURL      string
+	SessionHash []byte
+	// RPCID is the number of calls made with the session, restoring it makes the request ids
+	// continue where they left off instead of starting over, which the remote service would notice
+	RPCID uint64
+}
+
+// Snapshot captures the state of the session, it is nil as long as the session has no auth ticket
+func (s *Session) Snapshot() *SessionSnapshot {
+	if !s.hasTicket {
+		return nil
+	}
+	return &SessionSnapshot{
+		AuthTicket:  s.ticket,
+		APIURL:      s.url,
+		SessionHash: append([]byte(nil), s.hash...),
+		RPCID:       s.RPCID,
+	}
+}
+
+// Restore resumes the session from a snapshot instead of performing Init, the request ids continue
+// after the ones used before the snapshot was taken
+func (s *Session) Restore(snapshot *SessionSnapshot) error {
+	if snapshot == nil {
+		return ErrNoAuthTicket
+	}
+	if len(snapshot.SessionHash) > 0 {
+		err := s.SetSessionHash(snapshot.SessionHash)
+		if err != nil {
+			return err
+		}
+	}
+	err := s.SetAuthTicket(snapshot.AuthTicket, snapshot.APIURL)
+	if err != nil {
+		return err
+	}
+	s.RPCID = snapshot.RPCID
+	return nil
+}
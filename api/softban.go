@@ -0,0 +1,43 @@
+package api
+
+import (
+	protos "github.com/pogodevorg/POGOProtos-go"
+)
+
+// softBanDetector counts the map responses in a row that have forts but no pokémon at all,
+// which is how a soft banned account sees the map
+type softBanDetector struct {
+	threshold int
+	count     int
+}
+
+// SetSoftBanThreshold makes Announce give ErrSoftBanned once this many map responses in a row had forts
+// but no pokémon, zero disables the detection
+func (s *Session) SetSoftBanThreshold(threshold int) {
+	s.softBan.threshold = threshold
+}
+
+// SoftBanCount returns the number of map responses in a row that had forts but no pokémon
+func (s *Session) SoftBanCount() int {
+	s.callMu.Lock()
+	defer s.callMu.Unlock()
+	return s.softBan.count
+}
+
+// track counts the map response and checks whether the threshold has been reached
+func (d *softBanDetector) track(mapObjects *protos.GetMapObjectsResponse) error {
+	forts, pokemon := 0, 0
+	for _, cell := range mapObjects.MapCells {
+		forts += len(cell.Forts)
+		pokemon += len(cell.WildPokemons) + len(cell.CatchablePokemons) + len(cell.NearbyPokemons)
+	}
+	if forts == 0 || pokemon > 0 {
+		d.count = 0
+		return nil
+	}
+	d.count++
+	if d.threshold > 0 && d.count >= d.threshold {
+		return ErrSoftBanned
+	}
+	return nil
+}
@@ -0,0 +1,71 @@
+package api
+
+import (
+	"github.com/golang/geo/s2"
+	"golang.org/x/net/context"
+
+	protos "github.com/pogodevorg/POGOProtos-go"
+)
+
+// spawnPointLevel is the level of the s2 cell a spawn point id refers to
+const spawnPointLevel = 20
+
+// MapSpawnPoint is a spawn point listed in the map cells
+type MapSpawnPoint struct {
+	// ID is the spawn point id as used by the wild pokémon spawning on it
+	ID        string
+	Latitude  float64
+	Longitude float64
+	// Decimated is set for spawn points that are listed as decimated, which no longer spawn pokémon
+	Decimated bool
+}
+
+// spawnPointID gives the id of the spawn point at the given coordinates
+func spawnPointID(lat, lng float64) string {
+	return s2.CellIDFromLatLng(s2.LatLngFromDegrees(lat, lng)).Parent(spawnPointLevel).ToToken()
+}
+
+// SpawnPoints flattens the spawn points and decimated spawn points of all the map cells
+// into a list without duplicates, in the order they are listed
+func SpawnPoints(mapObjects *protos.GetMapObjectsResponse) []MapSpawnPoint {
+	var spawnPoints []MapSpawnPoint
+	if mapObjects == nil {
+		return spawnPoints
+	}
+
+	seen := make(map[string]bool)
+	add := func(spawnPoint *protos.SpawnPoint, decimated bool) {
+		if spawnPoint == nil {
+			return
+		}
+		id := spawnPointID(spawnPoint.Latitude, spawnPoint.Longitude)
+		if seen[id] {
+			return
+		}
+		seen[id] = true
+		spawnPoints = append(spawnPoints, MapSpawnPoint{
+			ID:        id,
+			Latitude:  spawnPoint.Latitude,
+			Longitude: spawnPoint.Longitude,
+			Decimated: decimated,
+		})
+	}
+	for _, cell := range mapObjects.MapCells {
+		for _, spawnPoint := range cell.SpawnPoints {
+			add(spawnPoint, false)
+		}
+		for _, spawnPoint := range cell.DecimatedSpawnPoints {
+			add(spawnPoint, true)
+		}
+	}
+	return spawnPoints
+}
+
+// GetSpawnPoints returns the spawn points in the surrounding map cells
+func (s *Session) GetSpawnPoints(ctx context.Context, proxyId int64) ([]MapSpawnPoint, error) {
+	mapObjects, err := s.GetPlayerMap(ctx, proxyId)
+	if err != nil {
+		return nil, err
+	}
+	return SpawnPoints(mapObjects), nil
+}
@@ -0,0 +1,58 @@
+package api
+
+import (
+	"sync"
+	"time"
+
+	protos "github.com/pogodevorg/POGOProtos-go"
+)
+
+// SessionStats describes how long a session has been alive and how many calls it made
+type SessionStats struct {
+	// Label is the account label of the session, see SetAccountLabel
+	Label        string
+	Uptime       time.Duration
+	Calls        int
+	CallsByType  map[protos.RequestType]int
+	LastCallTime time.Time
+}
+
+// callCounter counts the calls of a session, it may be read while the session is in use
+type callCounter struct {
+	mutex  sync.Mutex
+	calls  int
+	byType map[protos.RequestType]int
+	last   time.Time
+}
+
+func (c *callCounter) count(requests []*protos.Request) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.byType == nil {
+		c.byType = make(map[protos.RequestType]int)
+	}
+	c.calls++
+	for _, request := range requests {
+		c.byType[request.RequestType]++
+	}
+	c.last = time.Now()
+}
+
+// Stats returns the time since the session was created and the calls it made
+func (s *Session) Stats() SessionStats {
+	s.callCounter.mutex.Lock()
+	defer s.callCounter.mutex.Unlock()
+
+	stats := SessionStats{
+		Label:        s.label,
+		Uptime:       time.Since(s.started),
+		Calls:        s.callCounter.calls,
+		CallsByType:  make(map[protos.RequestType]int, len(s.callCounter.byType)),
+		LastCallTime: s.callCounter.last,
+	}
+	for requestType, calls := range s.callCounter.byType {
+		stats.CallsByType[requestType] = calls
+	}
+	return stats
+}
@@ -0,0 +1,56 @@
+package api
+
+import (
+	"errors"
+
+	"github.com/golang/protobuf/proto"
+	protos "github.com/pogodevorg/POGOProtos-go"
+	"golang.org/x/net/context"
+)
+
+// SetTeamCheck sets whether SetPlayerTeam checks the cached player data for an existing team before
+// sending the request, the check is enabled by default
+func (s *Session) SetTeamCheck(enabled bool) {
+	s.skipTeamCheck = !enabled
+}
+
+// SetPlayerTeam picks the team of the player, when the cached player data already has a team
+// it gives ErrTeamAlreadySet without sending a request
+func (s *Session) SetPlayerTeam(ctx context.Context, team protos.TeamColor, proxyId int64) (*protos.SetPlayerTeamResponse, error) {
+	if !s.skipTeamCheck {
+		player := s.playerCache.player
+		if player != nil && player.PlayerData != nil && player.PlayerData.Team != protos.TeamColor_NEUTRAL {
+			return nil, ErrTeamAlreadySet
+		}
+	}
+
+	requestMessage, err := proto.Marshal(&protos.SetPlayerTeamMessage{
+		Team: team,
+	})
+	if err != nil {
+		return nil, ErrFormatting
+	}
+
+	requests := []*protos.Request{{RequestType: protos.RequestType_SET_PLAYER_TEAM, RequestMessage: requestMessage}}
+	response, err := s.Call(ctx, requests, proxyId)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(response.Returns) < 1 {
+		return nil, errors.New("Empty response")
+	}
+
+	setTeam := &protos.SetPlayerTeamResponse{}
+	err = unmarshalChecked(response.Returns[0], setTeam)
+	if err != nil {
+		return nil, err
+	}
+	if setTeam.Status == protos.SetPlayerTeamResponse_SUCCESS && setTeam.PlayerData != nil && s.playerCache.player != nil {
+		s.playerCache.player.PlayerData = setTeam.PlayerData
+	}
+	s.feed.Push(setTeam)
+	s.debugProtoMessage("response return[0]", setTeam)
+
+	return setTeam, newErrCall(requests, response, GetErrorFromStatus(response.StatusCode))
+}
@@ -0,0 +1,60 @@
+package api
+
+import (
+	"time"
+
+	"golang.org/x/net/context"
+
+	protos "github.com/pogodevorg/POGOProtos-go"
+)
+
+// throttleBackoff is the pause after the first throttled response, it doubles with every throttled response in a row
+const throttleBackoff = 2 * time.Second
+
+// maxThrottleBackoff is the longest pause after a throttled response
+const maxThrottleBackoff = time.Minute
+
+// throttle keeps the calls back while the remote service is throttling the session
+type throttle struct {
+	backoff time.Duration
+	until   time.Time
+}
+
+// track extends the backoff on a throttled response and clears it on any other response
+func (t *throttle) track(status protos.ResponseEnvelope_StatusCode) {
+	if status != protos.ResponseEnvelope_INVALID_PLATFORM_REQUEST {
+		t.backoff = 0
+		t.until = time.Time{}
+		return
+	}
+	if t.backoff == 0 {
+		t.backoff = throttleBackoff
+	} else if t.backoff < maxThrottleBackoff {
+		t.backoff *= 2
+		if t.backoff > maxThrottleBackoff {
+			t.backoff = maxThrottleBackoff
+		}
+	}
+	t.until = time.Now().Add(t.backoff)
+}
+
+// waitUntil blocks until the given time has passed
+func waitUntil(ctx context.Context, until time.Time) error {
+	wait := time.Until(until)
+	if wait <= 0 {
+		return nil
+	}
+	select {
+	case <-time.After(wait):
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// ThrottledUntil returns until when calls are held back because the remote service throttled the session
+func (s *Session) ThrottledUntil() time.Time {
+	s.callMu.Lock()
+	defer s.callMu.Unlock()
+	return s.throttle.until
+}
@@ -0,0 +1,92 @@
+package api
+
+import (
+	"time"
+
+	"golang.org/x/net/context"
+
+	protos "github.com/pogodevorg/POGOProtos-go"
+)
+
+// Default bounds of the pause between the steps of WarmUp
+const (
+	defaultWarmUpMinDelay = 500 * time.Millisecond
+	defaultWarmUpMaxDelay = 1500 * time.Millisecond
+)
+
+// warmUpDelays holds the bounds of the pause between the steps of WarmUp
+type warmUpDelays struct {
+	min time.Duration
+	max time.Duration
+}
+
+// sample returns a pause between min and max for r in [0, 1), the defaults are used when no delays have been set
+func (d *warmUpDelays) sample(r float64) time.Duration {
+	min, max := d.min, d.max
+	if min == 0 && max == 0 {
+		min, max = defaultWarmUpMinDelay, defaultWarmUpMaxDelay
+	}
+	if max <= min {
+		return min
+	}
+	return min + time.Duration(r*float64(max-min))
+}
+
+// SetWarmUpDelays sets the bounds of the random pause WarmUp takes between its requests
+func (s *Session) SetWarmUpDelays(min, max time.Duration) {
+	if max < min {
+		min, max = max, min
+	}
+	s.warmUp = warmUpDelays{min: min, max: max}
+}
+
+// WarmUp performs the requests the game does right after logging in, with a short pause between them:
+// the player, the inventory, the settings and a challenge check. It should be called after Init and
+// before any other action, when the account has been flagged for a challenge ErrCheckChallenge is returned
+func (s *Session) WarmUp(ctx context.Context, proxyId int64) error {
+	steps := []func() error{
+		func() error {
+			_, err := s.RefreshPlayer(ctx, proxyId)
+			return err
+		},
+		func() error {
+			_, err := s.GetInventory(ctx, proxyId)
+			return err
+		},
+		func() error {
+			requests := []*protos.Request{s.getDownloadSettingsRequest()}
+			response, err := s.Call(ctx, requests, proxyId)
+			if err != nil {
+				return err
+			}
+			s.cacheSettings(requests, response)
+			return newErrCall(requests, response, GetErrorFromStatus(response.StatusCode))
+		},
+		func() error {
+			requests := []*protos.Request{{RequestType: protos.RequestType_CHECK_CHALLENGE}}
+			response, err := s.Call(ctx, requests, proxyId)
+			if err != nil {
+				return err
+			}
+			if s.HasChallenge() {
+				return ErrCheckChallenge
+			}
+			return newErrCall(requests, response, GetErrorFromStatus(response.StatusCode))
+		},
+	}
+
+	for i, step := range steps {
+		if i > 0 {
+			select {
+			case <-time.After(s.pause(s.warmUp.sample(s.randFloat64()))):
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+		err := step()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
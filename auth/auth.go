@@ -4,6 +4,8 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"strings"
+	"time"
 
 	"github.com/muxgo/pgoapi-go/auth/google"
 	"github.com/muxgo/pgoapi-go/auth/ptc"
@@ -16,6 +18,16 @@ type Provider interface {
 	GetAccessToken() string
 }
 
+// ExpiringProvider is a Provider that knows when the access token of its last login expires,
+// it is optional so existing providers keep satisfying Provider
+type ExpiringProvider interface {
+	Provider
+	GetExpiry() time.Time
+}
+
+var _ ExpiringProvider = (*ptc.Provider)(nil)
+var _ ExpiringProvider = (*google.Provider)(nil)
+
 // UnknownProvider is a null provider for when a real one cannot be retrieved
 type UnknownProvider struct {
 }
@@ -35,9 +47,13 @@ func (u *UnknownProvider) GetAccessToken() string {
 	return ""
 }
 
-// NewProvider creates a new provider based on the provider identifier
+// NewProvider creates a new provider based on the provider identifier, "ptc" or "google"
 func NewProvider(provider, username, password string) (Provider, error) {
-	switch provider {
+	if username == "" || password == "" {
+		return &UnknownProvider{}, errors.New("A username and password are required")
+	}
+
+	switch strings.ToLower(strings.TrimSpace(provider)) {
 	case "ptc":
 		return ptc.NewProvider(username, password), nil
 	case "google":
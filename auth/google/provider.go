@@ -12,7 +12,9 @@ import (
 	"math/big"
 	"net/http"
 	"net/url"
+	"strconv"
 	"strings"
+	"time"
 )
 
 const androidKeyBase64 = "AAAAgMom/1a/v0lblO2Ubrt60J2gcuXSljGFQXgcyZWveWLEwo6prwgi3iJIZdodyhKZQrNWp5nKJ3srRXcUW+F1BD3baEVGcmEgqaLZUNBjm057pKRI16kB0YppeGx5qIQ5QjKzsR8ETQbKLNWgRY0QRNVz34kMJR3P/LgHax/6rmf5AAAAAwEAAQ=="
@@ -28,6 +30,7 @@ type Provider struct {
 	username string
 	password string
 	ticket   string
+	expiry   time.Time
 	http     *http.Client
 }
 
@@ -51,6 +54,11 @@ func (p *Provider) GetAccessToken() string {
 	return p.ticket
 }
 
+// GetExpiry will return the time the access token expires, or the zero time if it is not known
+func (p *Provider) GetExpiry() time.Time {
+	return p.expiry
+}
+
 // Login retrieves an access token from the Pokémon Trainer's Club
 func (p *Provider) Login(ctx context.Context) (string, error) {
 	sig, err := signature(p.username, p.password)
@@ -97,17 +105,28 @@ func (p *Provider) Login(ctx context.Context) (string, error) {
 		return "", err
 	}
 
+	var ticket string
+	var expiry time.Time
 	for _, line := range strings.Split(string(decompressedBody), "\n") {
 		sp := strings.SplitN(line, "=", 2)
 		if len(sp) != 2 {
 			continue
 		}
-		if sp[0] == "Auth" {
-			p.ticket = sp[1]
-			return p.ticket, nil
+		switch sp[0] {
+		case "Auth":
+			ticket = sp[1]
+		case "Expiry":
+			if seconds, err := strconv.ParseInt(sp[1], 10, 64); err == nil {
+				expiry = time.Unix(seconds, 0)
+			}
 		}
 	}
-	return "", fmt.Errorf("No Auth found")
+	if ticket == "" {
+		return "", fmt.Errorf("No Auth found")
+	}
+	p.ticket = ticket
+	p.expiry = expiry
+	return p.ticket, nil
 }
 
 func signature(email, password string) (string, error) {
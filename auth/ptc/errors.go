@@ -1,9 +1,13 @@
 package ptc
 
 import (
+	"errors"
 	"fmt"
 )
 
+// ErrThrottled happens when the Pokémon Trainer's Club temporarily refuses the login because of load or rate limiting
+var ErrThrottled = errors.New("auth/ptc: Login was throttled")
+
 // LoginError is thrown when something went wrong with the login request
 type LoginError struct {
 	message string
@@ -8,7 +8,9 @@ import (
 	"net/http"
 	"net/http/cookiejar"
 	"net/url"
+	"strconv"
 	"strings"
+	"time"
 
 	"golang.org/x/net/context/ctxhttp"
 )
@@ -22,6 +24,9 @@ const clientID = "mobile-app_pokemon-go"
 
 const providerString = "ptc"
 
+const defaultMaxRetries = 3
+const retryBackoff = time.Second
+
 type loginRequest struct {
 	Lt        string   `json:"lt"`
 	Execution string   `json:"execution"`
@@ -30,10 +35,12 @@ type loginRequest struct {
 
 // Provider contains data about and manages the session with the Pokémon Trainer's Club
 type Provider struct {
-	username string
-	password string
-	ticket   string
-	http     *http.Client
+	username   string
+	password   string
+	ticket     string
+	expiry     time.Time
+	http       *http.Client
+	maxRetries int
 }
 
 // NewProvider constructs a Pokémon Trainer's Club auth provider instance
@@ -48,12 +55,22 @@ func NewProvider(username, password string) *Provider {
 	}
 
 	return &Provider{
-		http:     httpClient,
-		username: username,
-		password: password,
+		http:       httpClient,
+		username:   username,
+		password:   password,
+		maxRetries: defaultMaxRetries,
 	}
 }
 
+// SetMaxRetries sets how many times a throttled login is retried
+func (p *Provider) SetMaxRetries(retries int) {
+	p.maxRetries = retries
+}
+
+func isThrottled(resp *http.Response) bool {
+	return resp.StatusCode == http.StatusServiceUnavailable || resp.StatusCode == http.StatusTooManyRequests
+}
+
 // GetProviderString will return a string identifying the provider
 func (p *Provider) GetProviderString() string {
 	return providerString
@@ -64,8 +81,31 @@ func (p *Provider) GetAccessToken() string {
 	return p.ticket
 }
 
-// Login retrieves an access token from the Pokémon Trainer's Club
+// GetExpiry will return the time the access token expires, or the zero time if it is not known
+func (p *Provider) GetExpiry() time.Time {
+	return p.expiry
+}
+
+// Login retrieves an access token from the Pokémon Trainer's Club,
+// throttled attempts are retried with an increasing delay
 func (p *Provider) Login(ctx context.Context) (string, error) {
+	backoff := retryBackoff
+	for attempt := 0; ; attempt++ {
+		ticket, err := p.login(ctx)
+		if err != ErrThrottled || attempt >= p.maxRetries {
+			return ticket, err
+		}
+
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return "", ctx.Err()
+		}
+		backoff *= 2
+	}
+}
+
+func (p *Provider) login(ctx context.Context) (string, error) {
 	req1, _ := http.NewRequest("GET", loginURL, nil)
 	req1.Header.Set("User-Agent", "niantic")
 	// fmt.Println(loginURL)
@@ -77,6 +117,9 @@ func (p *Provider) Login(ctx context.Context) (string, error) {
 	}
 
 	defer resp1.Body.Close()
+	if isThrottled(resp1) {
+		return "", ErrThrottled
+	}
 	body1, _ := ioutil.ReadAll(resp1.Body)
 	var loginRespBody loginRequest
 	json.Unmarshal(body1, &loginRespBody)
@@ -100,6 +143,10 @@ func (p *Provider) Login(ctx context.Context) (string, error) {
 	if resp2 == nil {
 		return "", err2
 	}
+	if isThrottled(resp2) {
+		resp2.Body.Close()
+		return "", ErrThrottled
+	}
 	if _, ok2 := err2.(*url.Error); !ok2 {
 		body2, _ := ioutil.ReadAll(resp2.Body)
 		resp2.Body.Close()
@@ -133,11 +180,19 @@ func (p *Provider) Login(ctx context.Context) (string, error) {
 	if err3 != nil {
 		return loginError("Could not authorize code")
 	}
+	defer resp3.Body.Close()
+	if isThrottled(resp3) {
+		return "", ErrThrottled
+	}
 
 	b, _ := ioutil.ReadAll(resp3.Body)
 	query, _ := url.ParseQuery(string(b))
 
 	p.ticket = query.Get("access_token")
+	p.expiry = time.Time{}
+	if expires, err := strconv.Atoi(query.Get("expires")); err == nil {
+		p.expiry = time.Now().Add(time.Duration(expires) * time.Second)
+	}
 	// fmt.Println("logged", p.ticket)
 
 	return p.ticket, nil